@@ -0,0 +1,57 @@
+package keepalive
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	strategyAnalytics         = "analytics"
+	defaultAnalyticsStatement = "SELECT 1"
+)
+
+// analyticsStrategy runs a read-only statement against the Analytics
+// service, keeping its connection warm.
+type analyticsStrategy struct {
+	cluster   *gocb.Cluster
+	statement string
+	timeout   time.Duration
+}
+
+func (s analyticsStrategy) Ping(ctx context.Context) error {
+	result, err := s.cluster.AnalyticsQuery(s.statement, &gocb.AnalyticsOptions{
+		Context:  ctx,
+		Timeout:  s.timeout,
+		Readonly: true,
+	})
+	if err != nil {
+		return err
+	}
+	// Drain the rows so the request completes and its connection is released.
+	for result.Next() {
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+	return result.Close()
+}
+
+// verifyService checks that at least one node of the cluster runs service,
+// so a strategy that needs it fails at startup rather than on every tick.
+func verifyService(ctx context.Context, bucket *gocb.Bucket, service gocb.ServiceType, timeout time.Duration) error {
+	result, err := bucket.Ping(&gocb.PingOptions{
+		ServiceTypes: []gocb.ServiceType{service},
+		Timeout:      timeout,
+		Context:      ctx,
+	})
+	if err != nil {
+		return fmt.Errorf("check %s service: %w", serviceName(service), err)
+	}
+	if len(result.Services[service]) == 0 {
+		return fmt.Errorf("%s service is not deployed on the cluster", serviceName(service))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package keepalive
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// newCertificateAuthenticator loads a PEM client certificate and key pair for
+// certificate-based authentication.
+func newCertificateAuthenticator(certPath, keyPath string) (gocb.CertificateAuthenticator, error) {
+	if certPath == "" || keyPath == "" {
+		return gocb.CertificateAuthenticator{}, errors.New("COUCHBASE_CLIENT_CERT_PATH and COUCHBASE_CLIENT_KEY_PATH must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return gocb.CertificateAuthenticator{}, fmt.Errorf("load client certificate %s / key %s: %w", certPath, keyPath, err)
+	}
+	return gocb.CertificateAuthenticator{ClientCertificate: &cert}, nil
+}
+
+// rereadPassword re-reads the password file after authentication failures,
+// so a password rotated by a secret manager is used for the reconnect. It
+// does nothing unless the password came from COUCHBASE_PASSWORD_FILE. Callers
+// must hold c.mu.
+func (c *clusterConn) rereadPassword() {
+	if c.cfg.PasswordFile == "" || c.cfg.ClientCertPath != "" {
+		return
+	}
+	slog.Warn("Authentication failing, re-reading the password file", "cluster", c.cfg.Name, "file", c.cfg.PasswordFile)
+	password, err := readSecretFile(c.cfg.PasswordFile)
+	if err != nil {
+		slog.Error("Could not re-read the password file", "cluster", c.cfg.Name, "err", err)
+		return
+	}
+	if password == c.cfg.Password {
+		slog.Info("Password file unchanged", "cluster", c.cfg.Name)
+		return
+	}
+	c.cfg.Password = password
+	keepaliveCredentialRotations.WithLabelValues(c.cfg.Name).Inc()
+	slog.Info("Password file changed, reconnecting with the new password", "cluster", c.cfg.Name)
+}
+
+// saslMechanisms maps config names to the SASL mechanisms the SDK supports.
+var saslMechanisms = map[string]gocb.SaslMechanism{
+	"PLAIN":        gocb.PlainSaslMechanism,
+	"SCRAM-SHA1":   gocb.ScramSha1SaslMechanism,
+	"SCRAM-SHA256": gocb.ScramSha256SaslMechanism,
+	"SCRAM-SHA512": gocb.ScramSha512SaslMechanism,
+}
+
+// parseSaslMechanisms resolves mechanism names such as "SCRAM-SHA512".
+func parseSaslMechanisms(names []string) ([]gocb.SaslMechanism, error) {
+	var mechanisms []gocb.SaslMechanism
+	for _, name := range names {
+		mechanism, ok := saslMechanisms[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid auth mechanism %q: must be PLAIN, SCRAM-SHA1, SCRAM-SHA256 or SCRAM-SHA512 (COUCHBASE_AUTH_MECHANISMS)", name)
+		}
+		mechanisms = append(mechanisms, mechanism)
+	}
+	return mechanisms, nil
+}
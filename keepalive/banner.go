@@ -0,0 +1,104 @@
+package keepalive
+
+import (
+	"cmp"
+	"log/slog"
+	"strings"
+)
+
+// logBanner logs one line summarizing what this process is about to do,
+// built from the resolved config: the build, and for every cluster where
+// it connects, what it keeps alive, how it authenticates and whether TLS
+// is verified. Secrets are never part of it.
+func logBanner(cfg Config) {
+	build := CurrentBuild()
+	attrs := []any{
+		"version", build.Version,
+		"commit", build.Commit,
+		"admin", disabledIfEmpty(cfg.MetricsListenAddr),
+		"output", cfg.OutputFormat,
+	}
+	for _, cc := range cfg.clusterConfigs() {
+		name := cc.Name
+		if name == "" {
+			name = "cluster"
+		}
+		attrs = append(attrs, slog.Group(name, clusterBanner(cc)...))
+	}
+	slog.Info("Starting couchbase-keepalive", attrs...)
+}
+
+// clusterBanner describes one cluster for the startup banner.
+func clusterBanner(cfg Config) []any {
+	// validate has already checked the connection string.
+	spec, _ := parseConnectionString(cfg.ConnectionString)
+	attrs := []any{
+		"hosts", spec.hosts,
+		"auth", authMode(cfg),
+		"tls", tlsMode(cfg, spec.scheme),
+		"bucket", cfg.BucketName,
+	}
+	var strategies []string
+	for _, tc := range cfg.keepaliveTargets() {
+		name := tc.activeStrategy()
+		if tc.usesCollections() && tc.DiscoverScope != "" {
+			strategies = append(strategies, name+":"+tc.DiscoverScope+".*")
+			continue
+		}
+		if tc.usesCollections() {
+			// validate has already checked the targets.
+			targets, _ := tc.targets()
+			for _, t := range targets {
+				strategies = append(strategies, name+":"+t.String())
+			}
+			continue
+		}
+		strategies = append(strategies, name)
+	}
+	attrs = append(attrs, "keepalives", strategies, "interval", cfg.Interval)
+	if cfg.ReadOnly {
+		attrs = append(attrs, "readonly", true)
+	}
+	if cfg.ConfigProfile != "" {
+		attrs = append(attrs, "profile", cfg.ConfigProfile)
+	}
+	if network := cmp.Or(cfg.Network, spec.network); network != "" {
+		attrs = append(attrs, "network", network)
+	}
+	return attrs
+}
+
+// authMode describes how cfg authenticates, naming the user but never the
+// password.
+func authMode(cfg Config) string {
+	if cfg.ClientCertPath != "" {
+		return "certificate " + cfg.ClientCertPath
+	}
+	mode := "password as " + cfg.Username
+	if len(cfg.AuthMechanisms) > 0 {
+		mode += " (" + strings.Join(cfg.AuthMechanisms, ", ") + ")"
+	}
+	return mode
+}
+
+// tlsMode describes whether the connection uses TLS and how the cluster's
+// certificate is verified.
+func tlsMode(cfg Config, scheme string) string {
+	switch {
+	case scheme == "couchbase":
+		return "off"
+	case cfg.TLSInsecure:
+		return "on, verification DISABLED"
+	case cfg.CACertPath != "":
+		return "on, verified against " + cfg.CACertPath
+	}
+	return "on, verified against the system roots"
+}
+
+// disabledIfEmpty returns value, or "disabled" when it is empty.
+func disabledIfEmpty(value string) string {
+	if value == "" {
+		return "disabled"
+	}
+	return value
+}
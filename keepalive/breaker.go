@@ -0,0 +1,88 @@
+package keepalive
+
+import (
+	"log/slog"
+	"time"
+)
+
+const defaultBreakerCooldown = time.Minute
+
+// Circuit breaker states, as reported on /healthz.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// breaker stops a loop from hammering a cluster that keeps failing. After
+// threshold consecutive failed ticks it opens, and from then on allows a
+// single probe per cooldown until one succeeds and closes it again. A nil
+// breaker is always closed. It is only touched by the loop's run goroutine.
+type breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	failures int
+	state    string
+	openedAt time.Time
+}
+
+// newBreaker returns a breaker for the named target, or nil when threshold
+// is zero.
+func newBreaker(name string, threshold int, cooldown time.Duration) *breaker {
+	if threshold == 0 {
+		return nil
+	}
+	return &breaker{name: name, threshold: threshold, cooldown: cooldown, state: breakerClosed}
+}
+
+// allow reports whether the next tick may run. Once the cooldown of an open
+// breaker has passed, it moves to half-open and allows one probe.
+func (b *breaker) allow() bool {
+	if b == nil || b.state == breakerClosed {
+		return true
+	}
+	if b.state == breakerOpen && time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.state = breakerHalfOpen
+	return true
+}
+
+// probing reports whether the next tick is the probe of a half-open breaker.
+func (b *breaker) probing() bool {
+	return b != nil && b.state == breakerHalfOpen
+}
+
+// record updates the breaker with the outcome of a tick.
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	if err == nil {
+		if b.state != breakerClosed {
+			slog.Info("Circuit breaker closed", "target", b.name)
+		}
+		b.failures, b.state = 0, breakerClosed
+		return
+	}
+	b.failures++
+	switch {
+	case b.state == breakerHalfOpen:
+		slog.Warn("Circuit breaker probe failed", "target", b.name, "cooldown", b.cooldown)
+	case b.failures >= b.threshold:
+		slog.Warn("Circuit breaker opened", "target", b.name, "failures", b.failures, "cooldown", b.cooldown)
+	default:
+		return
+	}
+	b.state, b.openedAt = breakerOpen, time.Now()
+}
+
+// current returns the breaker state, or "" for a nil breaker.
+func (b *breaker) current() string {
+	if b == nil {
+		return ""
+	}
+	return b.state
+}
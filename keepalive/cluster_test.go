@@ -0,0 +1,54 @@
+package keepalive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+func TestClusterOptionsTimeoutsOverrideProfile(t *testing.T) {
+	var profile gocb.ClusterOptions
+	if err := profile.ApplyProfile(gocb.ClusterConfigProfileWanDevelopment); err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.ConfigProfile = "wan-development"
+	cfg.KVTimeout = 7 * time.Second
+	cfg.QueryTimeout = 11 * time.Second
+	options, err := clusterOptions(cfg)
+	if err != nil {
+		t.Fatalf("clusterOptions: %v", err)
+	}
+
+	got, want := options.TimeoutsConfig, profile.TimeoutsConfig
+	if got.KVTimeout != cfg.KVTimeout {
+		t.Errorf("KV timeout = %s, want the override %s, not the profile's %s", got.KVTimeout, cfg.KVTimeout, want.KVTimeout)
+	}
+	if got.QueryTimeout != cfg.QueryTimeout {
+		t.Errorf("query timeout = %s, want the override %s, not the profile's %s", got.QueryTimeout, cfg.QueryTimeout, want.QueryTimeout)
+	}
+	// Timeouts that are not overridden keep the profile's value.
+	if got.ConnectTimeout != want.ConnectTimeout || got.ConnectTimeout == 0 {
+		t.Errorf("connect timeout = %s, want the profile's %s", got.ConnectTimeout, want.ConnectTimeout)
+	}
+	if got.ManagementTimeout != want.ManagementTimeout {
+		t.Errorf("management timeout = %s, want the profile's %s", got.ManagementTimeout, want.ManagementTimeout)
+	}
+}
+
+func TestClusterOptionsWithoutProfile(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConnectTimeout = 3 * time.Second
+	options, err := clusterOptions(cfg)
+	if err != nil {
+		t.Fatalf("clusterOptions: %v", err)
+	}
+	if got := options.TimeoutsConfig.ConnectTimeout; got != cfg.ConnectTimeout {
+		t.Errorf("connect timeout = %s, want %s", got, cfg.ConnectTimeout)
+	}
+	if got := options.TimeoutsConfig.KVTimeout; got != 0 {
+		t.Errorf("KV timeout = %s, want 0 so the SDK default applies", got)
+	}
+}
@@ -0,0 +1,44 @@
+package keepalive
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// pingConcurrently returns an operation that runs n pings of strategy at
+// once and waits for all of them, as a light soak test of the connection.
+// The increment strategy moves on to its next counter document for every
+// ping, so with at least n documents each one writes a different document.
+// The operation fails with the first error when any ping failed.
+func pingConcurrently(name string, n int, strategy KeepaliveStrategy) func(context.Context) error {
+	return func(ctx context.Context) error {
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = strategy.Ping(ctx)
+			}()
+		}
+		wg.Wait()
+
+		var failed int
+		var first error
+		for _, err := range errs {
+			if err != nil {
+				if failed == 0 {
+					first = err
+				}
+				failed++
+			}
+		}
+		slog.Debug("Concurrent keepalives finished", "target", name, "succeeded", n-failed, "failed", failed)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d concurrent keepalives failed, first: %w", failed, n, first)
+		}
+		return nil
+	}
+}
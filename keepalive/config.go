@@ -0,0 +1,1114 @@
+package keepalive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultKeepaliveInterval = time.Minute
+	minKeepaliveInterval     = time.Second
+	defaultCounterDocID      = "counter"
+	defaultMaxRetries        = 3
+	defaultMaxRetryDelay     = 30 * time.Second
+	initialRetryDelay        = time.Second
+	defaultReadyTimeout      = 5 * time.Second
+	defaultConnectBackoff    = 5 * time.Second
+	maxConnectBackoff        = time.Minute
+	defaultShutdownTimeout   = 10 * time.Second
+	defaultOpTimeout         = 2500 * time.Millisecond
+	defaultReconnectAfter    = 3
+	defaultCounterInitial    = 1
+	defaultCounterDelta      = 1
+	defaultRampFactor        = 2
+	defaultRampWindow        = 10 * time.Minute
+)
+
+// Config holds every setting of the keepalive. It is populated from
+// defaults, then an optional YAML or JSON file, then environment variables,
+// each layer overriding the previous one.
+//
+// Clusters optionally lists several clusters to keep alive from one process.
+// Each entry inherits every setting from the top level and overrides the
+// ones it sets; process-wide settings such as logging and the admin server
+// are only read from the top level.
+//
+// Targets likewise optionally lists several keepalives against one bucket,
+// each with its own strategy, collections and interval, inheriting from the
+// cluster they belong to. Connection settings are only read from the cluster.
+//
+// PasswordFile is only set from COUCHBASE_PASSWORD_FILE. It is re-read when
+// authentication keeps failing, so a rotated password needs no restart.
+type Config struct {
+	Name     string   `yaml:"name,omitempty"`
+	Clusters []Config `yaml:"clusters,omitempty"`
+	Targets  []Config `yaml:"targets,omitempty"`
+
+	ConnectionString string   `yaml:"connection_string"`
+	Username         string   `yaml:"username"`
+	Password         string   `yaml:"password"`
+	AuthMechanisms   []string `yaml:"auth_mechanisms"`
+	ClientCertPath   string   `yaml:"client_cert_path"`
+	ClientKeyPath    string   `yaml:"client_key_path"`
+	CACertPath       string   `yaml:"ca_cert_path"`
+	TLSInsecure      bool     `yaml:"tls_insecure"`
+	ConfigProfile    string   `yaml:"config_profile"`
+	Network          string   `yaml:"network"`
+	PasswordFile     string   `yaml:"-"`
+
+	BucketName         string            `yaml:"bucket"`
+	BucketNames        []string          `yaml:"buckets"`
+	ScopeName          string            `yaml:"scope"`
+	CollectionName     string            `yaml:"collection"`
+	Collections        []string          `yaml:"collections"`
+	DiscoverScope      string            `yaml:"discover_scope"`
+	FallbackToDefault  bool              `yaml:"fallback_to_default"`
+	FallbackToUpsert   bool              `yaml:"fallback_to_upsert"`
+	CounterDocID       string            `yaml:"counter_doc_id"`
+	CounterDocIDs      []string          `yaml:"counter_doc_ids"`
+	CounterDocs        int               `yaml:"counter_docs"`
+	CounterExpiry      time.Duration     `yaml:"counter_expiry"`
+	CounterInitial     int               `yaml:"counter_initial"`
+	CounterDelta       int               `yaml:"counter_delta"`
+	CounterMaxValue    int               `yaml:"counter_max_value"`
+	Durability         string            `yaml:"durability"`
+	ReplicaCheckEvery  int               `yaml:"replica_check_every"`
+	TouchExpiry        time.Duration     `yaml:"touch_expiry"`
+	HeartbeatDocID     string            `yaml:"heartbeat_doc_id"`
+	HeartbeatFields    map[string]string `yaml:"heartbeat_fields"`
+	QueryScope         string            `yaml:"query_scope"`
+	QueryStatement     string            `yaml:"query_statement"`
+	AnalyticsStatement string            `yaml:"analytics_statement"`
+
+	Strategy               string        `yaml:"strategy"`
+	ReadOnly               bool          `yaml:"readonly"`
+	PingServices           []string      `yaml:"ping_services"`
+	ExtraPingServices      []string      `yaml:"extra_ping_services"`
+	WarmServices           []string      `yaml:"warm_services"`
+	WarmOnInterval         bool          `yaml:"warm_on_interval"`
+	TopologyPollInterval   time.Duration `yaml:"topology_poll_interval"`
+	CollectionRefresh      time.Duration `yaml:"collection_refresh_interval"`
+	DiscoveryInterval      time.Duration `yaml:"collection_discovery_interval"`
+	Interval               time.Duration `yaml:"interval"`
+	FailureInterval        time.Duration `yaml:"probe_interval_on_failure"`
+	RampStartInterval      time.Duration `yaml:"ramp_start_interval"`
+	RampFactor             float64       `yaml:"ramp_factor"`
+	RampWindow             time.Duration `yaml:"ramp_window"`
+	Concurrency            int           `yaml:"concurrency"`
+	StartupJitter          time.Duration `yaml:"startup_jitter"`
+	FireOnStart            bool          `yaml:"fire_on_start"`
+	RetryMaxAttempts       int           `yaml:"retry_max_attempts"`
+	RetryMaxBackoff        time.Duration `yaml:"retry_max_backoff"`
+	SDKRetryStrategy       string        `yaml:"sdk_retry_strategy"`
+	SDKRetryMaxBackoff     time.Duration `yaml:"sdk_retry_max_backoff"`
+	ReconnectAfterFailures int           `yaml:"reconnect_after_failures"`
+	MaxConsecutiveFailures int           `yaml:"max_consecutive_failures"`
+	BreakerThreshold       int           `yaml:"circuit_breaker_threshold"`
+	BreakerCooldown        time.Duration `yaml:"circuit_breaker_cooldown"`
+	OpTimeout              time.Duration `yaml:"op_timeout"`
+	SlowThreshold          time.Duration `yaml:"slow_threshold"`
+	ReadyTimeout           time.Duration `yaml:"ready_timeout"`
+	ConnectRetries         int           `yaml:"connect_retries"`
+	ConnectRetryBackoff    time.Duration `yaml:"connect_retry_backoff"`
+	ConnectRetryDeadline   time.Duration `yaml:"connect_retry_deadline"`
+	BucketType             string        `yaml:"bucket_type"`
+	SkipReadyWait          bool          `yaml:"skip_ready_wait"`
+	ConnectTimeout         time.Duration `yaml:"connect_timeout"`
+	KVTimeout              time.Duration `yaml:"kv_timeout"`
+	QueryTimeout           time.Duration `yaml:"query_timeout"`
+	ShutdownTimeout        time.Duration `yaml:"shutdown_timeout"`
+	ShutdownSignals        []string      `yaml:"shutdown_signals"`
+	RunOnce                bool          `yaml:"run_once"`
+	MaxRuntime             time.Duration `yaml:"max_runtime"`
+	UptimeLogInterval      time.Duration `yaml:"uptime_log_interval"`
+
+	InstanceLabel        string        `yaml:"instance_label"`
+	StatusFile           string        `yaml:"status_file"`
+	MetricsListenAddr    string        `yaml:"metrics_listen_addr"`
+	AdminToken           string        `yaml:"admin_token"`
+	GRPCHealthListenAddr string        `yaml:"grpc_health_listen_addr"`
+	HealthGracePeriod    time.Duration `yaml:"health_grace_period"`
+	HistorySize          int           `yaml:"history_size"`
+	EnablePprof          bool          `yaml:"enable_pprof"`
+	OTLPEndpoint         string        `yaml:"otlp_endpoint"`
+	FailureWebhookURL    string        `yaml:"failure_webhook_url"`
+	WebhookDebounce      time.Duration `yaml:"failure_webhook_debounce"`
+
+	OutputFormat  string `yaml:"output_format"`
+	LogFormat     string `yaml:"log_format"`
+	LogLevel      string `yaml:"log_level"`
+	LogTimestamps string `yaml:"log_timestamps"`
+	LogCaller     bool   `yaml:"log_caller"`
+	LogFile       string `yaml:"log_file"`
+	LogEveryN     int    `yaml:"log_every_n"`
+	LogMaxSizeMB  int    `yaml:"log_max_size_mb"`
+	LogMaxBackups int    `yaml:"log_max_backups"`
+}
+
+// DefaultConfig returns a Config with every optional setting at its default.
+func DefaultConfig() Config {
+	return Config{
+		CounterDocID:           defaultCounterDocID,
+		CounterDocs:            1,
+		CounterInitial:         defaultCounterInitial,
+		CounterDelta:           defaultCounterDelta,
+		Durability:             "none",
+		TouchExpiry:            defaultTouchExpiry,
+		HeartbeatDocID:         defaultHeartbeatDocID,
+		QueryStatement:         defaultQueryStatement,
+		AnalyticsStatement:     defaultAnalyticsStatement,
+		Strategy:               strategyIncrement,
+		PingServices:           defaultPingServices,
+		Interval:               defaultKeepaliveInterval,
+		RampFactor:             defaultRampFactor,
+		RampWindow:             defaultRampWindow,
+		Concurrency:            1,
+		RetryMaxAttempts:       defaultMaxRetries,
+		RetryMaxBackoff:        defaultMaxRetryDelay,
+		SDKRetryStrategy:       sdkRetryBestEffort,
+		SDKRetryMaxBackoff:     defaultSDKRetryMaxBackoff,
+		ReconnectAfterFailures: defaultReconnectAfter,
+		BreakerCooldown:        defaultBreakerCooldown,
+		OpTimeout:              defaultOpTimeout,
+		SlowThreshold:          defaultSlowThreshold,
+		ReadyTimeout:           defaultReadyTimeout,
+		ConnectRetryBackoff:    defaultConnectBackoff,
+		BucketType:             "couchbase",
+		ShutdownTimeout:        defaultShutdownTimeout,
+		ShutdownSignals:        defaultShutdownSignals,
+		InstanceLabel:          "{hostname}",
+		MetricsListenAddr:      defaultAdminListenAddr,
+		HealthGracePeriod:      defaultHealthGracePeriod,
+		HistorySize:            defaultHistorySize,
+		UptimeLogInterval:      defaultUptimeLogInterval,
+		WebhookDebounce:        defaultWebhookDebounce,
+		OutputFormat:           outputText,
+		LogFormat:              "text",
+		LogLevel:               "info",
+		LogTimestamps:          timestampsLocal,
+		DiscoveryInterval:      defaultDiscoveryInterval,
+		LogMaxSizeMB:           defaultLogMaxSizeMB,
+		LogEveryN:              1,
+		LogMaxBackups:          defaultLogMaxBackups,
+	}
+}
+
+// LoadConfig builds the configuration from defaults, the file at path (if
+// not empty), the overlay for APP_ENV next to it, the JSON object in
+// CONFIG_JSON and the individual environment variables, each overriding the
+// one before. All problems found are reported together.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	// data and targetsData are the sources that list clusters and
+	// top-level targets last, so their entries can be inherited below.
+	var data, targetsData []byte
+	targetsPath := path
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config file: %w", err)
+		}
+		if err := decodeConfig(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+		targetsData = data
+
+		if appEnv := os.Getenv("APP_ENV"); appEnv != "" {
+			overlayPath := overlayPath(path, appEnv)
+			overlay, err := os.ReadFile(overlayPath)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				slog.Warn("No config overlay for APP_ENV", "app_env", appEnv, "path", overlayPath)
+			case err != nil:
+				return Config{}, fmt.Errorf("read config file: %w", err)
+			default:
+				if err := decodeConfig(overlay, &cfg); err != nil {
+					return Config{}, fmt.Errorf("parse config file %s: %w", overlayPath, err)
+				}
+				// Clusters and targets are inherited from whichever file
+				// lists them last.
+				if hasClusters(overlay) {
+					data, path = overlay, overlayPath
+				}
+				if hasTargets(overlay) {
+					targetsData, targetsPath = overlay, overlayPath
+				}
+			}
+		}
+	}
+	if configJSON, isExist := os.LookupEnv("CONFIG_JSON"); isExist {
+		if err := decodeConfigFields([]byte(configJSON), &cfg); err != nil {
+			return Config{}, fmt.Errorf("invalid CONFIG_JSON: %w", err)
+		}
+		if hasClusters([]byte(configJSON)) {
+			data, path = []byte(configJSON), "CONFIG_JSON"
+		}
+		if hasTargets([]byte(configJSON)) {
+			targetsData, targetsPath = []byte(configJSON), "CONFIG_JSON"
+		}
+	}
+
+	var env envLoader
+	env.secret("COUCHBASE_CONNECTION_STRING", &cfg.ConnectionString)
+	env.secret("COUCHBASE_USERNAME", &cfg.Username)
+	env.secret("COUCHBASE_PASSWORD", &cfg.Password)
+	env.string("COUCHBASE_PASSWORD_FILE", &cfg.PasswordFile)
+	env.list("COUCHBASE_AUTH_MECHANISMS", &cfg.AuthMechanisms)
+	env.string("COUCHBASE_CLIENT_CERT_PATH", &cfg.ClientCertPath)
+	env.string("COUCHBASE_CLIENT_KEY_PATH", &cfg.ClientKeyPath)
+	env.string("COUCHBASE_CA_CERT_PATH", &cfg.CACertPath)
+	env.bool("COUCHBASE_TLS_INSECURE", &cfg.TLSInsecure)
+	env.string("COUCHBASE_CONFIG_PROFILE", &cfg.ConfigProfile)
+	env.string("COUCHBASE_NETWORK", &cfg.Network)
+	env.string("COUCHBASE_BUCKET_NAME", &cfg.BucketName)
+	env.list("COUCHBASE_BUCKET_NAMES", &cfg.BucketNames)
+	env.string("COUCHBASE_SCOPE_NAME", &cfg.ScopeName)
+	env.string("COUCHBASE_COLLECTION_NAME", &cfg.CollectionName)
+	env.list("COUCHBASE_COLLECTIONS", &cfg.Collections)
+	env.string("COUCHBASE_DISCOVER_SCOPE", &cfg.DiscoverScope)
+	env.bool("FALLBACK_TO_DEFAULT", &cfg.FallbackToDefault)
+	env.bool("FALLBACK_TO_UPSERT", &cfg.FallbackToUpsert)
+	env.string("COUCHBASE_COUNTER_DOC_ID", &cfg.CounterDocID)
+	env.list("COUCHBASE_COUNTER_DOC_IDS", &cfg.CounterDocIDs)
+	env.int("COUNTER_DOCS", &cfg.CounterDocs)
+	env.duration("COUNTER_EXPIRY", &cfg.CounterExpiry)
+	env.int("COUNTER_INITIAL", &cfg.CounterInitial)
+	env.int("COUNTER_DELTA", &cfg.CounterDelta)
+	env.int("COUNTER_MAX_VALUE", &cfg.CounterMaxValue)
+	env.string("COUCHBASE_DURABILITY", &cfg.Durability)
+	env.int("REPLICA_CHECK_EVERY", &cfg.ReplicaCheckEvery)
+	env.duration("TOUCH_EXPIRY", &cfg.TouchExpiry)
+	env.string("HEARTBEAT_DOC_ID", &cfg.HeartbeatDocID)
+	env.fields("HEARTBEAT_FIELDS", &cfg.HeartbeatFields)
+	env.string("QUERY_SCOPE", &cfg.QueryScope)
+	env.string("QUERY_STATEMENT", &cfg.QueryStatement)
+	env.string("ANALYTICS_STATEMENT", &cfg.AnalyticsStatement)
+	env.string("KEEPALIVE_STRATEGY", &cfg.Strategy)
+	env.bool("READONLY", &cfg.ReadOnly)
+	env.list("KEEPALIVE_PING_SERVICES", &cfg.PingServices)
+	env.list("KEEPALIVE_EXTRA_PING_SERVICES", &cfg.ExtraPingServices)
+	env.list("WARM_SERVICES", &cfg.WarmServices)
+	env.bool("WARM_ON_INTERVAL", &cfg.WarmOnInterval)
+	env.duration("TOPOLOGY_POLL_INTERVAL", &cfg.TopologyPollInterval)
+	env.duration("COLLECTION_REFRESH_INTERVAL", &cfg.CollectionRefresh)
+	env.duration("COLLECTION_DISCOVERY_INTERVAL", &cfg.DiscoveryInterval)
+	env.duration("COUCHBASE_KEEPALIVE_INTERVAL", &cfg.Interval)
+	env.duration("PROBE_INTERVAL_ON_FAILURE", &cfg.FailureInterval)
+	env.duration("RAMP_START_INTERVAL", &cfg.RampStartInterval)
+	env.float("RAMP_FACTOR", &cfg.RampFactor)
+	env.duration("RAMP_WINDOW", &cfg.RampWindow)
+	env.int("CONCURRENCY", &cfg.Concurrency)
+	env.duration("STARTUP_JITTER", &cfg.StartupJitter)
+	env.bool("FIRE_ON_START", &cfg.FireOnStart)
+	env.int("COUCHBASE_RETRY_MAX_ATTEMPTS", &cfg.RetryMaxAttempts)
+	env.duration("COUCHBASE_RETRY_MAX_BACKOFF", &cfg.RetryMaxBackoff)
+	env.string("COUCHBASE_SDK_RETRY_STRATEGY", &cfg.SDKRetryStrategy)
+	env.duration("COUCHBASE_SDK_RETRY_MAX_BACKOFF", &cfg.SDKRetryMaxBackoff)
+	env.int("COUCHBASE_RECONNECT_AFTER_FAILURES", &cfg.ReconnectAfterFailures)
+	env.int("MAX_CONSECUTIVE_FAILURES", &cfg.MaxConsecutiveFailures)
+	env.int("CIRCUIT_BREAKER_THRESHOLD", &cfg.BreakerThreshold)
+	env.duration("CIRCUIT_BREAKER_COOLDOWN", &cfg.BreakerCooldown)
+	env.duration("COUCHBASE_OP_TIMEOUT", &cfg.OpTimeout)
+	env.duration("SLOW_THRESHOLD", &cfg.SlowThreshold)
+	env.duration("COUCHBASE_READY_TIMEOUT", &cfg.ReadyTimeout)
+	env.int("COUCHBASE_CONNECT_RETRIES", &cfg.ConnectRetries)
+	env.duration("COUCHBASE_CONNECT_RETRY_BACKOFF", &cfg.ConnectRetryBackoff)
+	env.duration("COUCHBASE_CONNECT_RETRY_DEADLINE", &cfg.ConnectRetryDeadline)
+	env.string("BUCKET_TYPE", &cfg.BucketType)
+	env.bool("SKIP_READY_WAIT", &cfg.SkipReadyWait)
+	env.duration("COUCHBASE_CONNECT_TIMEOUT", &cfg.ConnectTimeout)
+	env.duration("COUCHBASE_KV_TIMEOUT", &cfg.KVTimeout)
+	env.duration("COUCHBASE_QUERY_TIMEOUT", &cfg.QueryTimeout)
+	env.duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	env.list("SHUTDOWN_SIGNALS", &cfg.ShutdownSignals)
+	env.bool("COUCHBASE_RUN_ONCE", &cfg.RunOnce)
+	env.duration("MAX_RUNTIME", &cfg.MaxRuntime)
+	env.duration("UPTIME_LOG_INTERVAL", &cfg.UptimeLogInterval)
+	env.string("INSTANCE_LABEL", &cfg.InstanceLabel)
+	env.string("STATUS_FILE", &cfg.StatusFile)
+	env.string("METRICS_LISTEN_ADDR", &cfg.MetricsListenAddr)
+	env.secret("ADMIN_TOKEN", &cfg.AdminToken)
+	env.string("GRPC_HEALTH_LISTEN_ADDR", &cfg.GRPCHealthListenAddr)
+	env.duration("HEALTH_GRACE_PERIOD", &cfg.HealthGracePeriod)
+	env.int("HISTORY_SIZE", &cfg.HistorySize)
+	env.bool("ENABLE_PPROF", &cfg.EnablePprof)
+	env.string("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.OTLPEndpoint)
+	env.string("FAILURE_WEBHOOK_URL", &cfg.FailureWebhookURL)
+	env.duration("FAILURE_WEBHOOK_DEBOUNCE", &cfg.WebhookDebounce)
+	env.string("OUTPUT_FORMAT", &cfg.OutputFormat)
+	env.string("LOG_FORMAT", &cfg.LogFormat)
+	env.string("LOG_LEVEL", &cfg.LogLevel)
+	env.string("LOG_TIMESTAMPS", &cfg.LogTimestamps)
+	env.bool("LOG_CALLER", &cfg.LogCaller)
+	env.string("LOG_FILE", &cfg.LogFile)
+	env.int("LOG_EVERY_N", &cfg.LogEveryN)
+	env.int("LOG_MAX_SIZE_MB", &cfg.LogMaxSizeMB)
+	env.int("LOG_MAX_BACKUPS", &cfg.LogMaxBackups)
+
+	instance, err := expandHostname(cfg.InstanceLabel)
+	if err != nil {
+		return Config{}, fmt.Errorf("instance label: %w (INSTANCE_LABEL)", err)
+	}
+	cfg.InstanceLabel = instance
+
+	if len(cfg.Targets) > 0 {
+		targets, err := inheritTargets(targetsData, cfg)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse config file %s: %w", targetsPath, err)
+		}
+		cfg.Targets = targets
+	}
+	if len(cfg.Clusters) > 0 {
+		clusters, err := inheritClusters(data, targetsData, cfg)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+		cfg.Clusters = clusters
+	}
+	// A variable that does not parse leaves its field as it was, so the
+	// rest is still validated and every problem is reported at once.
+	if err := errors.Join(append(env.errs, cfg.validate())...); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// decodeConfig decodes a YAML or JSON config file on top of cfg, so every
+// field it sets replaces the value already there.
+func decodeConfig(data []byte, cfg *Config) error {
+	// YAML is a superset of JSON, so this handles both formats.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// decodeConfigFields decodes a JSON object on top of cfg one field at a
+// time, so every error names the field it is about.
+func decodeConfigFields(data []byte, cfg *Config) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("must be a JSON object")
+	}
+	var errs []error
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		field, err := yaml.Marshal(&yaml.Node{Kind: yaml.MappingNode, Content: root.Content[i : i+2]})
+		if err == nil {
+			err = decodeConfig(field, cfg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field %q: %w", key.Value, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// overlayPath returns the environment overlay for a config file, e.g.
+// config.prod.yaml for config.yaml and APP_ENV=prod.
+func overlayPath(path, appEnv string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + appEnv + ext
+}
+
+// hasClusters reports whether a config file lists clusters.
+func hasClusters(data []byte) bool {
+	var raw struct {
+		Clusters []yaml.Node `yaml:"clusters"`
+	}
+	return yaml.Unmarshal(data, &raw) == nil && len(raw.Clusters) > 0
+}
+
+// hasTargets reports whether a config file lists top-level targets.
+func hasTargets(data []byte) bool {
+	var raw struct {
+		Targets []yaml.Node `yaml:"targets"`
+	}
+	return yaml.Unmarshal(data, &raw) == nil && len(raw.Targets) > 0
+}
+
+// inheritClusters decodes each entry under "clusters" on top of a copy of
+// base, so entries only need to set what differs from the top level. The
+// targets of a cluster, its own or else the top-level ones in targetsData,
+// inherit from the cluster.
+func inheritClusters(data, targetsData []byte, base Config) ([]Config, error) {
+	var raw struct {
+		Clusters []yaml.Node `yaml:"clusters"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	base.Name = ""
+	base.Clusters = nil
+
+	clusters := make([]Config, 0, len(raw.Clusters))
+	for i, node := range raw.Clusters {
+		c := base
+		c.Targets = nil
+		if err := node.Decode(&c); err != nil {
+			return nil, fmt.Errorf("parse clusters[%d]: %w", i, err)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("cluster-%d", i+1)
+		}
+		// An entry with its own password does not follow the file.
+		if c.Password != base.Password {
+			c.PasswordFile = ""
+		}
+		targets := targetsData
+		var err error
+		if len(c.Targets) > 0 {
+			targets, err = yaml.Marshal(&node)
+		}
+		if err == nil {
+			c.Targets, err = inheritTargets(targets, c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse clusters[%d]: %w", i, err)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+// inheritTargets decodes each entry under "targets" in data on top of a
+// copy of base, so entries only need to set what differs from their cluster.
+func inheritTargets(data []byte, base Config) ([]Config, error) {
+	var raw struct {
+		Targets []yaml.Node `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	base.Clusters, base.Targets = nil, nil
+
+	var targets []Config
+	for i, node := range raw.Targets {
+		t := base
+		if err := node.Decode(&t); err != nil {
+			return nil, fmt.Errorf("parse targets[%d]: %w", i, err)
+		}
+		if len(t.Clusters) > 0 || len(t.Targets) > 0 {
+			return nil, fmt.Errorf("parse targets[%d]: a target cannot list clusters or targets", i)
+		}
+		t.Name = base.Name
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// keepaliveTargets returns the settings of every keepalive of a cluster:
+// its entries under targets, or the cluster itself when it has none.
+func (c Config) keepaliveTargets() []Config {
+	if len(c.Targets) == 0 {
+		return []Config{c}
+	}
+	return c.Targets
+}
+
+// clusterConfigs returns the configuration of every cluster to keep alive.
+// A cluster with several BucketNames is split into one per bucket, named
+// after the cluster and the bucket, each with a connection of its own.
+func (c Config) clusterConfigs() []Config {
+	clusters := c.Clusters
+	if len(clusters) == 0 {
+		clusters = []Config{c}
+	}
+	var configs []Config
+	for _, cc := range clusters {
+		if len(cc.BucketNames) == 0 {
+			configs = append(configs, cc)
+			continue
+		}
+		for _, bucket := range cc.BucketNames {
+			b := cc
+			b.BucketName, b.BucketNames = bucket, nil
+			b.Name = bucket
+			if cc.Name != "" {
+				b.Name = cc.Name + "/" + bucket
+			}
+			configs = append(configs, b)
+		}
+	}
+	return configs
+}
+
+// validate reports every missing or invalid setting, or nil.
+func (c Config) validate() error {
+	var errs []error
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown timeout must be positive, got %s (SHUTDOWN_TIMEOUT)", c.ShutdownTimeout))
+	}
+	if _, err := ParseSignals(c.ShutdownSignals); err != nil {
+		errs = append(errs, err)
+	}
+	if c.FailureWebhookURL != "" {
+		if err := checkWebhookURL(c.FailureWebhookURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.WebhookDebounce < 0 {
+		errs = append(errs, fmt.Errorf("failure webhook debounce must not be negative, got %s (FAILURE_WEBHOOK_DEBOUNCE)", c.WebhookDebounce))
+	}
+	if c.HistorySize < 0 {
+		errs = append(errs, fmt.Errorf("history size must not be negative, got %d (HISTORY_SIZE)", c.HistorySize))
+	}
+	if c.EnablePprof && c.MetricsListenAddr == "" {
+		errs = append(errs, errors.New("profiling is served on the admin server, which is disabled (ENABLE_PPROF, METRICS_LISTEN_ADDR)"))
+	}
+	if c.MaxRuntime < 0 {
+		errs = append(errs, fmt.Errorf("max runtime must not be negative, got %s (MAX_RUNTIME)", c.MaxRuntime))
+	}
+	if c.UptimeLogInterval != 0 && c.UptimeLogInterval < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("uptime log interval must be 0 or at least %s, got %s (UPTIME_LOG_INTERVAL)", minKeepaliveInterval, c.UptimeLogInterval))
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkLogFormat(c.LogFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkLogTimestamps(c.LogTimestamps); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkOutputFormat(c.OutputFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if c.LogFile != "" {
+		if c.LogMaxSizeMB <= 0 {
+			errs = append(errs, fmt.Errorf("log max size must be positive, got %d (LOG_MAX_SIZE_MB)", c.LogMaxSizeMB))
+		}
+		if c.LogMaxBackups < 0 {
+			errs = append(errs, fmt.Errorf("log max backups must not be negative, got %d (LOG_MAX_BACKUPS)", c.LogMaxBackups))
+		}
+	}
+	if c.OTLPEndpoint != "" {
+		if err := checkOTLPEndpoint(c.OTLPEndpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(c.Clusters) == 0 {
+		errs = append(errs, c.validateCluster()...)
+	}
+	seen := make(map[string]bool)
+	for _, cluster := range c.Clusters {
+		if seen[cluster.Name] {
+			errs = append(errs, fmt.Errorf("duplicate cluster name %q", cluster.Name))
+		}
+		seen[cluster.Name] = true
+		for _, err := range cluster.validateCluster() {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", cluster.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateCluster checks the settings that apply to a single cluster.
+func (c Config) validateCluster() []error {
+	var errs []error
+	if c.ConnectionString == "" {
+		errs = append(errs, errors.New("connection string is required (COUCHBASE_CONNECTION_STRING)"))
+	} else if _, err := parseConnectionString(c.ConnectionString); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Network != "" {
+		if err := checkNetwork(c.Network, c.ConnectionString); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.ClientCertPath != "" || c.ClientKeyPath != "" {
+		if c.ClientCertPath == "" || c.ClientKeyPath == "" {
+			errs = append(errs, errors.New("client certificate and key must be set together (COUCHBASE_CLIENT_CERT_PATH, COUCHBASE_CLIENT_KEY_PATH)"))
+		} else if _, err := newCertificateAuthenticator(c.ClientCertPath, c.ClientKeyPath); err != nil {
+			// Checked here so a bad file fails at once instead of being
+			// retried like an unreachable cluster.
+			errs = append(errs, fmt.Errorf("%w (COUCHBASE_CLIENT_CERT_PATH, COUCHBASE_CLIENT_KEY_PATH)", err))
+		}
+	} else {
+		if c.Username == "" {
+			errs = append(errs, errors.New("username is required (COUCHBASE_USERNAME)"))
+		}
+		if c.Password == "" {
+			errs = append(errs, errors.New("password is required (COUCHBASE_PASSWORD)"))
+		}
+	}
+	if _, err := parseSaslMechanisms(c.AuthMechanisms); err != nil {
+		errs = append(errs, err)
+	}
+	if c.TLSInsecure && c.CACertPath != "" {
+		errs = append(errs, errors.New("a CA certificate and skipping TLS verification must not be set together (COUCHBASE_CA_CERT_PATH, COUCHBASE_TLS_INSECURE)"))
+	}
+	if c.CACertPath != "" {
+		if _, err := loadCACertPool(c.CACertPath); err != nil {
+			errs = append(errs, fmt.Errorf("%w (COUCHBASE_CA_CERT_PATH)", err))
+		}
+	}
+	if c.ConfigProfile != "" {
+		if _, err := parseConfigProfile(c.ConfigProfile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch {
+	case len(c.BucketNames) > 0 && c.BucketName != "":
+		errs = append(errs, errors.New("set either a bucket or a list of buckets, not both (COUCHBASE_BUCKET_NAME, COUCHBASE_BUCKET_NAMES)"))
+	case len(c.BucketNames) > 0:
+		seen := make(map[string]bool)
+		for _, bucket := range c.BucketNames {
+			if bucket == "" || seen[bucket] {
+				errs = append(errs, fmt.Errorf("invalid bucket list %q: names must be non-empty and distinct (COUCHBASE_BUCKET_NAMES)", strings.Join(c.BucketNames, ",")))
+				break
+			}
+			seen[bucket] = true
+		}
+	case c.BucketName == "":
+		errs = append(errs, errors.New("bucket is required (COUCHBASE_BUCKET_NAME or COUCHBASE_BUCKET_NAMES)"))
+	}
+	if len(c.ExtraPingServices) > 0 {
+		if _, err := parseServiceTypes(c.ExtraPingServices, "KEEPALIVE_EXTRA_PING_SERVICES"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(c.WarmServices) > 0 {
+		if _, err := parseServiceTypes(c.WarmServices, "WARM_SERVICES"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.TopologyPollInterval != 0 && c.TopologyPollInterval < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("topology poll interval must be 0 or at least %s, got %s (TOPOLOGY_POLL_INTERVAL)", minKeepaliveInterval, c.TopologyPollInterval))
+	}
+	if c.CollectionRefresh != 0 && c.CollectionRefresh < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("collection refresh interval must be 0 or at least %s, got %s (COLLECTION_REFRESH_INTERVAL)", minKeepaliveInterval, c.CollectionRefresh))
+	}
+	if c.DiscoveryInterval != 0 && c.DiscoveryInterval < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("collection discovery interval must be 0 or at least %s, got %s (COLLECTION_DISCOVERY_INTERVAL)", minKeepaliveInterval, c.DiscoveryInterval))
+	}
+	if err := checkSDKRetryStrategy(c.SDKRetryStrategy); err != nil {
+		errs = append(errs, err)
+	}
+	if c.SDKRetryStrategy == sdkRetryBestEffort && c.SDKRetryMaxBackoff < sdkRetryMinBackoff {
+		errs = append(errs, fmt.Errorf("SDK retry max backoff must be at least %s, got %s (COUCHBASE_SDK_RETRY_MAX_BACKOFF)", sdkRetryMinBackoff, c.SDKRetryMaxBackoff))
+	}
+	if c.ReconnectAfterFailures < 0 {
+		errs = append(errs, fmt.Errorf("reconnect threshold must not be negative, got %d (COUCHBASE_RECONNECT_AFTER_FAILURES)", c.ReconnectAfterFailures))
+	}
+	if _, err := readyWaitOptions(c.BucketType); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ReadyTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("ready timeout must be positive, got %s (COUCHBASE_READY_TIMEOUT)", c.ReadyTimeout))
+	}
+	if c.ConnectRetries < 0 {
+		errs = append(errs, fmt.Errorf("connect retries must not be negative, got %d (COUCHBASE_CONNECT_RETRIES)", c.ConnectRetries))
+	}
+	if c.ConnectRetries > 0 && c.ConnectRetryBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("connect retry backoff must be positive, got %s (COUCHBASE_CONNECT_RETRY_BACKOFF)", c.ConnectRetryBackoff))
+	}
+	if c.ConnectRetryDeadline < 0 {
+		errs = append(errs, fmt.Errorf("connect retry deadline must not be negative, got %s (COUCHBASE_CONNECT_RETRY_DEADLINE)", c.ConnectRetryDeadline))
+	}
+	// Zero leaves the profile or SDK default in place.
+	if c.ConnectTimeout < 0 {
+		errs = append(errs, fmt.Errorf("connect timeout must be positive, got %s (COUCHBASE_CONNECT_TIMEOUT)", c.ConnectTimeout))
+	}
+	if c.KVTimeout < 0 {
+		errs = append(errs, fmt.Errorf("kv timeout must be positive, got %s (COUCHBASE_KV_TIMEOUT)", c.KVTimeout))
+	}
+	if c.QueryTimeout < 0 {
+		errs = append(errs, fmt.Errorf("query timeout must be positive, got %s (COUCHBASE_QUERY_TIMEOUT)", c.QueryTimeout))
+	}
+	// The cluster's own settings still apply to its extra pings, and a
+	// target inherits every problem it does not override, so those are only
+	// reported once.
+	own := c.validateTarget()
+	errs = append(errs, own...)
+	reported := make(map[string]bool)
+	for _, err := range own {
+		reported[err.Error()] = true
+	}
+	for i, t := range c.Targets {
+		for _, err := range t.validateTarget() {
+			if !reported[err.Error()] {
+				errs = append(errs, fmt.Errorf("targets[%d]: %w", i, err))
+			}
+		}
+	}
+	return errs
+}
+
+// validateTarget checks the settings that apply to a single keepalive: the
+// strategy, its documents and its schedule.
+func (c Config) validateTarget() []error {
+	var errs []error
+	if err := checkStrategy(c.Strategy); err != nil {
+		errs = append(errs, err)
+	}
+	switch {
+	case c.DiscoverScope != "":
+		if c.ScopeName != "" || c.CollectionName != "" || len(c.Collections) > 0 {
+			errs = append(errs, errors.New("a discovered scope replaces the scope, collection and collection list; leave them unset (COUCHBASE_DISCOVER_SCOPE)"))
+		}
+		if c.FallbackToDefault {
+			errs = append(errs, errors.New("falling back to the default collection is not supported with a discovered scope (FALLBACK_TO_DEFAULT, COUCHBASE_DISCOVER_SCOPE)"))
+		}
+	case c.usesCollections():
+		if _, err := c.targets(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.activeStrategy() == strategyPing {
+		if _, err := parseServiceTypes(c.PingServices, "KEEPALIVE_PING_SERVICES"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.CounterExpiry < 0 {
+		errs = append(errs, fmt.Errorf("counter expiry must not be negative, got %s (COUNTER_EXPIRY)", c.CounterExpiry))
+	}
+	if c.activeStrategy() == strategyTransaction && c.CounterExpiry > 0 {
+		errs = append(errs, errors.New("counter expiry is not supported by the transaction strategy (COUNTER_EXPIRY)"))
+	}
+	if c.CounterDocs < 1 {
+		errs = append(errs, fmt.Errorf("counter document count must be at least 1, got %d (COUNTER_DOCS)", c.CounterDocs))
+	}
+	if c.CounterInitial < 0 {
+		errs = append(errs, fmt.Errorf("counter initial value must not be negative, got %d (COUNTER_INITIAL)", c.CounterInitial))
+	}
+	if c.CounterDelta <= 0 {
+		errs = append(errs, fmt.Errorf("counter delta must be positive, got %d (COUNTER_DELTA)", c.CounterDelta))
+	}
+	if c.CounterMaxValue != 0 && c.CounterMaxValue <= c.CounterInitial {
+		errs = append(errs, fmt.Errorf("counter max value must be 0 or greater than the initial value %d, got %d (COUNTER_MAX_VALUE)", c.CounterInitial, c.CounterMaxValue))
+	}
+	if _, err := parseDurability(c.Durability); err != nil {
+		errs = append(errs, err)
+	}
+	if c.activeStrategy() == strategyQuery && strings.TrimSpace(c.QueryStatement) == "" {
+		errs = append(errs, errors.New("query statement must not be empty (QUERY_STATEMENT)"))
+	}
+	if c.activeStrategy() == strategyAnalytics && strings.TrimSpace(c.AnalyticsStatement) == "" {
+		errs = append(errs, errors.New("analytics statement must not be empty (ANALYTICS_STATEMENT)"))
+	}
+	upserts := c.activeStrategy() == strategyUpsert || c.activeStrategy() == strategyIncrement && c.FallbackToUpsert
+	if upserts && c.HeartbeatDocID == "" {
+		errs = append(errs, errors.New("heartbeat document id must not be empty (HEARTBEAT_DOC_ID)"))
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.HeartbeatFields)) {
+		if key == "" || key == "ts" || key == "host" {
+			errs = append(errs, fmt.Errorf("invalid heartbeat field %q: must be non-empty and not ts or host (HEARTBEAT_FIELDS)", key))
+		}
+	}
+	if c.activeStrategy() == strategyTouch && c.TouchExpiry <= 0 {
+		errs = append(errs, fmt.Errorf("touch expiry must be positive, got %s (TOUCH_EXPIRY)", c.TouchExpiry))
+	}
+	if c.Interval < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("interval must be at least %s, got %s (COUCHBASE_KEEPALIVE_INTERVAL)", minKeepaliveInterval, c.Interval))
+	}
+	if c.FailureInterval != 0 && c.FailureInterval < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("probe interval on failure must be 0 or at least %s, got %s (PROBE_INTERVAL_ON_FAILURE)", minKeepaliveInterval, c.FailureInterval))
+	}
+	if c.RampStartInterval != 0 && c.RampStartInterval < minKeepaliveInterval {
+		errs = append(errs, fmt.Errorf("ramp start interval must be 0 or at least %s, got %s (RAMP_START_INTERVAL)", minKeepaliveInterval, c.RampStartInterval))
+	}
+	if c.RampStartInterval > 0 {
+		if c.RampFactor <= 1 {
+			errs = append(errs, fmt.Errorf("ramp factor must be greater than 1, got %g (RAMP_FACTOR)", c.RampFactor))
+		}
+		if c.RampWindow <= 0 {
+			errs = append(errs, fmt.Errorf("ramp window must be positive, got %s (RAMP_WINDOW)", c.RampWindow))
+		}
+	}
+	switch {
+	case c.Concurrency < 1:
+		errs = append(errs, fmt.Errorf("concurrency must be at least 1, got %d (CONCURRENCY)", c.Concurrency))
+	case c.Concurrency > 1 && c.activeStrategy() == strategyTransaction:
+		errs = append(errs, errors.New("concurrency is not supported by the transaction strategy, whose transactions would conflict on the counter document (CONCURRENCY)"))
+	case c.Concurrency > 1 && (c.activeStrategy() == strategyTouch || c.activeStrategy() == strategyUpsert):
+		errs = append(errs, fmt.Errorf("concurrency is not supported by the %s strategy, which writes a single document; use increment with COUNTER_DOCS instead (CONCURRENCY)", c.activeStrategy()))
+	case c.Concurrency > 1 && c.activeStrategy() == strategyIncrement && c.FallbackToUpsert:
+		errs = append(errs, errors.New("concurrency is not supported with the upsert fallback, which writes a single document (CONCURRENCY, FALLBACK_TO_UPSERT)"))
+	case c.Concurrency > 1 && c.activeStrategy() == strategyIncrement:
+		if ids, err := c.counterDocIDs(); err == nil && len(ids) < c.Concurrency {
+			errs = append(errs, fmt.Errorf("concurrency %d needs at least as many counter documents, got %d (CONCURRENCY, COUNTER_DOCS)", c.Concurrency, len(ids)))
+		}
+	}
+	if c.StartupJitter < 0 {
+		errs = append(errs, fmt.Errorf("startup jitter must not be negative, got %s (STARTUP_JITTER)", c.StartupJitter))
+	}
+	if c.RetryMaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("retry max attempts must not be negative, got %d (COUCHBASE_RETRY_MAX_ATTEMPTS)", c.RetryMaxAttempts))
+	}
+	if c.RetryMaxBackoff < initialRetryDelay {
+		errs = append(errs, fmt.Errorf("retry max backoff must be at least %s, got %s (COUCHBASE_RETRY_MAX_BACKOFF)", initialRetryDelay, c.RetryMaxBackoff))
+	}
+	if c.ReplicaCheckEvery < 0 {
+		errs = append(errs, fmt.Errorf("replica check interval must not be negative, got %d (REPLICA_CHECK_EVERY)", c.ReplicaCheckEvery))
+	}
+	if c.LogEveryN < 1 {
+		errs = append(errs, fmt.Errorf("log every n must be at least 1, got %d (LOG_EVERY_N)", c.LogEveryN))
+	}
+	if c.MaxConsecutiveFailures < 0 {
+		errs = append(errs, fmt.Errorf("max consecutive failures must not be negative, got %d (MAX_CONSECUTIVE_FAILURES)", c.MaxConsecutiveFailures))
+	}
+	if c.BreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("circuit breaker threshold must not be negative, got %d (CIRCUIT_BREAKER_THRESHOLD)", c.BreakerThreshold))
+	}
+	if c.BreakerThreshold > 0 && c.BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("circuit breaker cooldown must be positive, got %s (CIRCUIT_BREAKER_COOLDOWN)", c.BreakerCooldown))
+	}
+	if c.OpTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("operation timeout must be positive, got %s (COUCHBASE_OP_TIMEOUT)", c.OpTimeout))
+	}
+	if c.SlowThreshold < 0 {
+		errs = append(errs, fmt.Errorf("slow threshold must not be negative, got %s (SLOW_THRESHOLD)", c.SlowThreshold))
+	}
+	if c.HealthGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("health grace period must not be negative, got %s (HEALTH_GRACE_PERIOD)", c.HealthGracePeriod))
+	}
+	return errs
+}
+
+// activeStrategy returns the strategy to run. Read-only mode replaces the
+// strategies that write with a ping that only needs to reach the KV
+// service; the query and ping strategies are already read-only.
+func (c Config) activeStrategy() string {
+	if c.ReadOnly && c.writes() {
+		return strategyPing
+	}
+	return c.Strategy
+}
+
+// counterDocIDs returns the counter documents the increment strategy bumps
+// in turn, with {hostname} expanded: CounterDocIDs when set, otherwise
+// CounterDocs documents named after CounterDocID. The first is the one the
+// touch strategy and -check use.
+func (c Config) counterDocIDs() ([]string, error) {
+	ids := c.CounterDocIDs
+	if len(ids) == 0 {
+		ids = []string{c.CounterDocID}
+		if c.CounterDocs > 1 {
+			ids = nil
+			for i := range c.CounterDocs {
+				ids = append(ids, fmt.Sprintf("%s-%d", c.CounterDocID, i))
+			}
+		}
+	}
+	expanded := make([]string, len(ids))
+	for i, id := range ids {
+		var err error
+		if expanded[i], err = expandHostname(id); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// writes reports whether the configured strategy writes to the target
+// collections.
+func (c Config) writes() bool {
+	switch c.Strategy {
+	case strategyIncrement, strategyTouch, strategyUpsert, strategyTransaction:
+		return true
+	}
+	return false
+}
+
+// usesCollections reports whether the active strategy writes to the target
+// collections.
+func (c Config) usesCollections() bool {
+	return !c.ReadOnly && c.writes()
+}
+
+// discoversCollections reports whether any keepalive of the cluster keeps
+// the collections of a discovered scope alive.
+func (c Config) discoversCollections() bool {
+	for _, tc := range c.keepaliveTargets() {
+		if tc.DiscoverScope != "" && tc.usesCollections() {
+			return true
+		}
+	}
+	return false
+}
+
+// targets returns the collections to keep alive: Collections when set,
+// otherwise the single ScopeName.CollectionName pair, or the bucket's
+// default collection when neither is set.
+func (c Config) targets() ([]target, error) {
+	if len(c.Collections) > 0 {
+		return parseTargets(c.Collections)
+	}
+	if c.ScopeName == "" && c.CollectionName == "" {
+		return []target{defaultTarget}, nil
+	}
+	if c.ScopeName == "" || c.CollectionName == "" {
+		return nil, errors.New("scope and collection must be set together (COUCHBASE_SCOPE_NAME, COUCHBASE_COLLECTION_NAME); leave both unset to use the default collection")
+	}
+	return []target{{scope: c.ScopeName, collection: c.CollectionName}}, nil
+}
+
+// ValidateTargets reports whether the collections of every cluster are
+// valid, whatever the strategy. Reset needs them even when the keepalive
+// itself does not.
+func (c Config) ValidateTargets() error {
+	var errs []error
+	for _, cc := range c.clusterConfigs() {
+		for i, tc := range cc.keepaliveTargets() {
+			_, err := tc.targets()
+			if err == nil {
+				continue
+			}
+			if len(cc.Targets) > 0 {
+				err = fmt.Errorf("targets[%d]: %w", i, err)
+			}
+			if len(c.Clusters) > 0 {
+				err = fmt.Errorf("cluster %s: %w", cc.Name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envLoader overrides config fields from environment variables that are
+// set, collecting parse errors rather than stopping at the first one.
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) string(key string, dst *string) {
+	if value, isExist := os.LookupEnv(key); isExist {
+		*dst = value
+	}
+}
+
+// secret is like string, but also reads the value from the file named by
+// key+"_FILE", as mounted Docker and Kubernetes secrets are. The file wins
+// when both are set.
+func (l *envLoader) secret(key string, dst *string) {
+	l.string(key, dst)
+	path, isExist := os.LookupEnv(key + "_FILE")
+	if !isExist {
+		return
+	}
+	value, err := readSecretFile(path)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("invalid %s_FILE: %w", key, err))
+		return
+	}
+	if _, isExist := os.LookupEnv(key); isExist {
+		slog.Warn("Both variable and file are set, using the file", "variable", key, "file", path)
+	}
+	*dst = value
+}
+
+// readSecretFile reads a secret from a file, dropping the trailing newline
+// editors and secret managers tend to leave.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), " \t\r\n"), nil
+}
+
+// list splits a comma-separated value, dropping empty entries.
+func (l *envLoader) list(key string, dst *[]string) {
+	value, isExist := os.LookupEnv(key)
+	if !isExist {
+		return
+	}
+	*dst = nil
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			*dst = append(*dst, entry)
+		}
+	}
+}
+
+// fields parses comma-separated key=value pairs such as
+// region=eu-west-1,tier=gold.
+func (l *envLoader) fields(key string, dst *map[string]string) {
+	value, isExist := os.LookupEnv(key)
+	if !isExist {
+		return
+	}
+	fields := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			l.errs = append(l.errs, fmt.Errorf("invalid %s entry %q: must be key=value", key, entry))
+			return
+		}
+		fields[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	*dst = fields
+}
+
+func (l *envLoader) duration(key string, dst *time.Duration) {
+	if value, isExist := os.LookupEnv(key); isExist {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+			return
+		}
+		*dst = d
+	}
+}
+
+func (l *envLoader) int(key string, dst *int) {
+	if value, isExist := os.LookupEnv(key); isExist {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (l *envLoader) float(key string, dst *float64) {
+	if value, isExist := os.LookupEnv(key); isExist {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (l *envLoader) bool(key string, dst *bool) {
+	if value, isExist := os.LookupEnv(key); isExist {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+			return
+		}
+		*dst = b
+	}
+}
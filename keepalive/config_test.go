@@ -0,0 +1,141 @@
+package keepalive
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigReportsParseAndValidationErrors(t *testing.T) {
+	t.Setenv("COUCHBASE_CONNECTION_STRING", "couchbase://localhost")
+	t.Setenv("COUCHBASE_USERNAME", "keepalive")
+	t.Setenv("COUCHBASE_PASSWORD", "secret")
+	t.Setenv("COUCHBASE_BUCKET_NAME", "")
+	t.Setenv("COUCHBASE_KEEPALIVE_INTERVAL", "soon")
+
+	_, err := LoadConfig("")
+	if err == nil {
+		t.Fatal("LoadConfig succeeded, want an error")
+	}
+	for _, want := range []string{"COUCHBASE_KEEPALIVE_INTERVAL", "bucket is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConfig error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		counterDocs int
+		fallback    bool
+		wantErr     bool
+	}{
+		{"increment with enough documents", strategyIncrement, 4, false, false},
+		{"increment with too few documents", strategyIncrement, 2, false, true},
+		{"increment with the upsert fallback", strategyIncrement, 4, true, true},
+		{"touch", strategyTouch, 4, false, true},
+		{"upsert", strategyUpsert, 4, false, true},
+		{"transaction", strategyTransaction, 4, false, true},
+		{"query", strategyQuery, 1, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ConnectionString = "couchbase://localhost"
+			cfg.Username, cfg.Password = "keepalive", "secret"
+			cfg.BucketName = "keepalive"
+			cfg.Strategy = tt.strategy
+			cfg.CounterDocs = tt.counterDocs
+			cfg.FallbackToUpsert = tt.fallback
+			cfg.Concurrency = 4
+			err := cfg.validate()
+			if got := err != nil && strings.Contains(err.Error(), "CONCURRENCY"); got != tt.wantErr {
+				t.Errorf("validate() = %v, want a concurrency error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCertificateFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeTestKeyPair(t, dir)
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		ca      string
+		wantErr string
+	}{
+		{"valid", certPath, keyPath, certPath, ""},
+		{"missing certificate", missing, keyPath, "", "COUCHBASE_CLIENT_CERT_PATH"},
+		{"key that does not parse", certPath, garbage, "", "COUCHBASE_CLIENT_KEY_PATH"},
+		{"missing CA", certPath, keyPath, missing, "COUCHBASE_CA_CERT_PATH"},
+		{"CA that does not parse", certPath, keyPath, garbage, "COUCHBASE_CA_CERT_PATH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.ConnectionString = "couchbases://localhost"
+			cfg.BucketName = "keepalive"
+			cfg.ClientCertPath, cfg.ClientKeyPath, cfg.CACertPath = tt.cert, tt.key, tt.ca
+			err := cfg.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validate() = %v, want an error naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeTestKeyPair writes a self-signed certificate and its key to dir and
+// returns their paths.
+func writeTestKeyPair(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "keepalive"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
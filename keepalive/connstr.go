@@ -0,0 +1,154 @@
+package keepalive
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// connSpec is the part of a connection string worth logging: the scheme,
+// the seed hosts, without any credentials, and the network option.
+type connSpec struct {
+	scheme  string
+	hosts   []string
+	network string
+}
+
+// connectionSchemes are the schemes the SDK can connect with. A connection
+// string without a scheme is treated as couchbase.
+var connectionSchemes = map[string]bool{
+	"couchbase":  true,
+	"couchbases": true,
+	"couchbase2": true,
+}
+
+// parseConnectionString checks a connection string such as
+// couchbases://host1,host2:11207?network=external before it is handed to
+// the SDK, which reports malformed strings only vaguely once connecting.
+func parseConnectionString(connStr string) (connSpec, error) {
+	var spec connSpec
+	rest := connStr
+	if scheme, after, ok := strings.Cut(connStr, "://"); ok {
+		spec.scheme, rest = scheme, after
+	} else {
+		spec.scheme = "couchbase"
+	}
+	if !connectionSchemes[spec.scheme] {
+		return connSpec{}, fmt.Errorf("invalid connection string: unsupported scheme %q: must be couchbase, couchbases or couchbase2 (COUCHBASE_CONNECTION_STRING)", spec.scheme)
+	}
+
+	authority, query := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority = rest[:i]
+		if q := strings.IndexByte(rest, '?'); q >= 0 {
+			query = rest[q+1:]
+		}
+	}
+	// Credentials embedded in the string are left to the SDK.
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		authority = authority[at+1:]
+	}
+	if authority == "" {
+		return connSpec{}, fmt.Errorf("invalid connection string: no hosts (COUCHBASE_CONNECTION_STRING)")
+	}
+	for _, host := range strings.FieldsFunc(authority, func(r rune) bool { return r == ',' || r == ';' }) {
+		if err := checkSeedHost(host); err != nil {
+			return connSpec{}, fmt.Errorf("invalid connection string: %w (COUCHBASE_CONNECTION_STRING)", err)
+		}
+		spec.hosts = append(spec.hosts, host)
+	}
+	if len(spec.hosts) == 0 {
+		return connSpec{}, fmt.Errorf("invalid connection string: no hosts (COUCHBASE_CONNECTION_STRING)")
+	}
+
+	options, err := url.ParseQuery(query)
+	if err != nil {
+		return connSpec{}, fmt.Errorf("invalid connection string: options: %w (COUCHBASE_CONNECTION_STRING)", err)
+	}
+	for name := range options {
+		if name == "" {
+			return connSpec{}, fmt.Errorf("invalid connection string: option without a name (COUCHBASE_CONNECTION_STRING)")
+		}
+	}
+	spec.network = options.Get("network")
+	return spec, nil
+}
+
+// networks are the network types the SDK can pick addresses from: auto
+// lets it choose, default forces the internal addresses and external the
+// alternate addresses the cluster advertises.
+var networks = []string{"auto", "default", "external"}
+
+// checkNetwork reports whether network is a known network type and does not
+// contradict the network option already in connStr.
+func checkNetwork(network, connStr string) error {
+	if !slices.Contains(networks, network) {
+		return fmt.Errorf("invalid network %q: must be auto, default or external (COUCHBASE_NETWORK)", network)
+	}
+	spec, err := parseConnectionString(connStr)
+	if err == nil && spec.network != "" && spec.network != network {
+		return fmt.Errorf("network %q contradicts network=%s in the connection string (COUCHBASE_NETWORK, COUCHBASE_CONNECTION_STRING)", network, spec.network)
+	}
+	return nil
+}
+
+// withNetwork adds network=<network> to the options of connStr, which is how
+// the SDK is told which addresses to use; ClusterOptions has no field for
+// it. An empty network, or one the string already names, leaves it as is.
+func withNetwork(connStr, network string) string {
+	if network == "" {
+		return connStr
+	}
+	if spec, err := parseConnectionString(connStr); err == nil && spec.network != "" {
+		return connStr
+	}
+	separator := "?"
+	if strings.Contains(connStr, "?") {
+		separator = "&"
+	}
+	return connStr + separator + "network=" + url.QueryEscape(network)
+}
+
+// checkSeedHost checks one seed host: a name, an IPv4 address or an IPv6
+// address in brackets, optionally followed by a port.
+func checkSeedHost(host string) error {
+	name, port := host, ""
+	if strings.HasPrefix(host, "[") {
+		end := strings.IndexByte(host, ']')
+		if end < 0 {
+			return fmt.Errorf("host %q: missing ] after IPv6 address", host)
+		}
+		name, port = host[1:end], host[end+1:]
+		if ip := net.ParseIP(name); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("host %q: invalid IPv6 address", host)
+		}
+		if port != "" {
+			if !strings.HasPrefix(port, ":") {
+				return fmt.Errorf("host %q: unexpected text after IPv6 address", host)
+			}
+			port = port[1:]
+		}
+	} else {
+		if strings.Count(host, ":") > 1 {
+			return fmt.Errorf("host %q: IPv6 addresses must be enclosed in brackets", host)
+		}
+		name, port, _ = strings.Cut(host, ":")
+		if name == "" {
+			return fmt.Errorf("host %q: empty host name", host)
+		}
+	}
+	if port == "" {
+		if strings.HasSuffix(host, ":") {
+			return fmt.Errorf("host %q: empty port", host)
+		}
+		return nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("host %q: invalid port %q", host, port)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package keepalive
+
+import (
+	"sort"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// notReadyServices reports the services that have at least one endpoint
+// which is not connected, according to the SDK's diagnostics report.
+func notReadyServices(cluster *gocb.Cluster) ([]string, error) {
+	report, err := cluster.Diagnostics(nil)
+	if err != nil {
+		return nil, err
+	}
+	var services []string
+	for service, endpoints := range report.Services {
+		for _, endpoint := range endpoints {
+			if endpoint.State != gocb.EndpointStateConnected {
+				services = append(services, service)
+				break
+			}
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
@@ -0,0 +1,165 @@
+package keepalive
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const defaultDiscoveryInterval = 5 * time.Minute
+
+// scopeStrategy keeps every collection of a scope alive by running the
+// collection strategy against each in turn. The collections are listed
+// through the collections manager on the first ping and again by discover,
+// so ones created while running are picked up without a restart.
+type scopeStrategy struct {
+	name    string
+	scope   string
+	bucket  *gocb.Bucket
+	timeout time.Duration
+	// build returns the collection strategy for a discovered collection.
+	build func(target) KeepaliveStrategy
+
+	// mu guards the discovered collections and their strategies, which
+	// discover replaces while pings are running.
+	mu         sync.Mutex
+	discovered bool
+	targets    []target
+	strategies []KeepaliveStrategy
+}
+
+// Ping runs the strategy of every discovered collection, discovering them
+// first if that has not happened yet. It fails when any collection fails,
+// reporting how many did and the first error, or when the scope is empty.
+func (s *scopeStrategy) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	discovered := s.discovered
+	s.mu.Unlock()
+	if !discovered {
+		if err := s.discover(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	targets, strategies := s.targets, s.strategies
+	s.mu.Unlock()
+	if len(strategies) == 0 {
+		return fmt.Errorf("no collections found in scope %s", s.scope)
+	}
+	var failed int
+	var first error
+	for i, strategy := range strategies {
+		if err := strategy.Ping(ctx); err != nil {
+			failed++
+			if first == nil {
+				first = fmt.Errorf("%s: %w", targets[i], err)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d collections failed, first: %w", failed, len(strategies), first)
+	}
+	return nil
+}
+
+// discover lists the collections of the scope and logs which were added or
+// removed since the last time. Collections that are still there keep their
+// strategy, and with it any state such as a fallback in use.
+func (s *scopeStrategy) discover(ctx context.Context) error {
+	targets, err := scopeCollections(ctx, s.bucket, s.scope, s.timeout)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := make(map[target]KeepaliveStrategy, len(s.targets))
+	for i, t := range s.targets {
+		existing[t] = s.strategies[i]
+	}
+	strategies := make([]KeepaliveStrategy, len(targets))
+	var added []string
+	for i, t := range targets {
+		strategy, ok := existing[t]
+		if !ok {
+			strategy = s.build(t)
+			added = append(added, t.String())
+		}
+		delete(existing, t)
+		strategies[i] = strategy
+	}
+	var removed []string
+	for t := range existing {
+		removed = append(removed, t.String())
+	}
+	slices.Sort(removed)
+
+	switch {
+	case !s.discovered:
+		slog.Info("Discovered collections", "target", s.name, "collections", added)
+	case len(added) > 0 || len(removed) > 0:
+		slog.Info("Discovered collections changed", "target", s.name, "added", added, "removed", removed)
+	}
+	s.discovered, s.targets, s.strategies = true, targets, strategies
+	return nil
+}
+
+// scopeCollections lists the collections of scope in bucket, sorted by name.
+func scopeCollections(ctx context.Context, bucket *gocb.Bucket, scope string, timeout time.Duration) ([]target, error) {
+	scopes, err := bucket.CollectionsV2().GetAllScopes(&gocb.GetAllScopesOptions{Timeout: timeout, Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("list collections of bucket %s: %w", bucket.Name(), err)
+	}
+	for _, s := range scopes {
+		if s.Name != scope {
+			continue
+		}
+		targets := make([]target, 0, len(s.Collections))
+		for _, collection := range s.Collections {
+			targets = append(targets, target{scope: scope, collection: collection.Name})
+		}
+		sort.Slice(targets, func(i, j int) bool { return targets[i].collection < targets[j].collection })
+		return targets, nil
+	}
+	return nil, fmt.Errorf("scope %s not found in bucket %s (COUCHBASE_DISCOVER_SCOPE)", scope, bucket.Name())
+}
+
+// collectionTargets returns the collections tc keeps alive: those of its
+// discovered scope as they are now, or the configured ones.
+func (c *clusterConn) collectionTargets(tc Config) ([]target, error) {
+	if tc.DiscoverScope == "" {
+		return tc.targets()
+	}
+	return scopeCollections(context.Background(), c.bucket, tc.DiscoverScope, tc.OpTimeout)
+}
+
+// discoverCollections rediscovers the collections of every keepalive of a
+// discovered scope every interval until ctx is done.
+func (c *clusterConn) discoverCollections(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		for _, k := range c.loops {
+			strategy, _ := k.current()
+			s, ok := strategy.(*scopeStrategy)
+			if !ok {
+				continue
+			}
+			if err := s.discover(ctx); err != nil {
+				slog.Warn("Could not discover collections", "target", k.name, "err", err)
+			}
+		}
+	}
+}
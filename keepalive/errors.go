@@ -0,0 +1,55 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// errorCategories groups the SDK's sentinel errors into broad categories
+// that can be alerted on separately. The first matching category wins.
+var errorCategories = []struct {
+	name string
+	errs []error
+}{
+	{"auth", []error{gocb.ErrAuthenticationFailure}},
+	{"timeout", []error{gocb.ErrTimeout, gocb.ErrAmbiguousTimeout, gocb.ErrUnambiguousTimeout, context.DeadlineExceeded}},
+	{"not_found", []error{gocb.ErrBucketNotFound, gocb.ErrScopeNotFound, gocb.ErrCollectionNotFound, gocb.ErrDocumentNotFound}},
+	{"unavailable", []error{gocb.ErrServiceNotAvailable, gocb.ErrTemporaryFailure, gocb.ErrOverload, gocb.ErrCircuitBreakerOpen, gocb.ErrRequestCanceled, gocb.ErrShutdown}},
+	{"durability", []error{gocb.ErrDurabilityLevelNotAvailable, gocb.ErrDurabilityImpossible, gocb.ErrDurabilityAmbiguous, gocb.ErrDurableWriteInProgress}},
+	{"rate_limited", []error{gocb.ErrRateLimitedFailure, gocb.ErrQuotaLimitedFailure}},
+}
+
+// permanentErrors are the errors retrying within the same tick cannot fix:
+// the request is not allowed, refers to something that does not exist or
+// is not supported, or was cancelled.
+var permanentErrors = []error{
+	gocb.ErrAuthenticationFailure,
+	gocb.ErrBucketNotFound, gocb.ErrScopeNotFound, gocb.ErrCollectionNotFound,
+	gocb.ErrInvalidArgument, gocb.ErrFeatureNotAvailable, gocb.ErrUnsupportedOperation,
+	context.Canceled,
+}
+
+// isRetryable reports whether err may go away when the operation is retried.
+func isRetryable(err error) bool {
+	for _, target := range permanentErrors {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+// errorCategory classifies err as auth, timeout, not_found, unavailable,
+// durability, rate_limited or other.
+func errorCategory(err error) string {
+	for _, c := range errorCategories {
+		for _, target := range c.errs {
+			if errors.Is(err, target) {
+				return c.name
+			}
+		}
+	}
+	return "other"
+}
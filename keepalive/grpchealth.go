@@ -0,0 +1,71 @@
+package keepalive
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcHealthRefresh is how often the gRPC health status is re-evaluated.
+const grpcHealthRefresh = time.Second
+
+// serveGRPCHealth implements the grpc.health.v1.Health service on addr
+// until ctx is cancelled. It reports SERVING for the overall service ("")
+// while every target is healthy by the same rules as /healthz, and
+// NOT_SERVING otherwise.
+func serveGRPCHealth(ctx context.Context, addr string, group healthGroup) {
+	lis, err := listen(addr)
+	if err != nil {
+		slog.Error("gRPC health server error", "err", err)
+		return
+	}
+	server := grpc.NewServer()
+	status := health.NewServer()
+	healthpb.RegisterHealthServer(server, status)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(lis)
+	}()
+	slog.Info("Serving gRPC health", "addr", addr)
+
+	ticker := time.NewTicker(grpcHealthRefresh)
+	defer ticker.Stop()
+	for {
+		serving := healthpb.HealthCheckResponse_SERVING
+		if !group.healthy() {
+			serving = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		status.SetServingStatus("", serving)
+
+		select {
+		case err := <-errCh:
+			slog.Error("gRPC health server error", "err", err)
+			return
+		case <-ctx.Done():
+			// Tell watchers the service is going away before closing.
+			status.Shutdown()
+			stopGracefully(server, serverShutdownTimeout)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopGracefully stops server, giving open streams up to timeout to finish.
+func stopGracefully(server *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		server.Stop()
+	}
+}
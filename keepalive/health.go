@@ -0,0 +1,133 @@
+package keepalive
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultHealthGracePeriod = 30 * time.Second
+
+// healthState tracks the outcome of recent keepalives against one target.
+type healthState struct {
+	name        string
+	mu          sync.Mutex
+	maxAge      time.Duration
+	lastSuccess time.Time
+	lastError   error
+	breaker     string
+	// paused is set while keepalives are paused; resumed is when they were
+	// last resumed, which counts as a success for the grace period.
+	paused  bool
+	resumed time.Time
+}
+
+// newHealthState returns a healthState that reports healthy while the last
+// success is no older than maxAge. The connection having just been
+// established counts as the first success.
+func newHealthState(name string, maxAge time.Duration) *healthState {
+	return &healthState{name: name, maxAge: maxAge, lastSuccess: time.Now()}
+}
+
+func (h *healthState) setMaxAge(maxAge time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxAge = maxAge
+}
+
+func (h *healthState) recordSuccess() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSuccess = time.Now()
+	h.lastError = nil
+}
+
+func (h *healthState) recordFailure(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastError = err
+}
+
+// setBreaker records the state of the target's circuit breaker; "" means
+// the target has none.
+func (h *healthState) setBreaker(state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.breaker = state
+}
+
+// setPaused records that keepalives were paused or resumed. A paused
+// target stays healthy, and the grace period restarts on resume.
+func (h *healthState) setPaused(paused bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.paused = paused
+	if !paused {
+		h.resumed = time.Now()
+	}
+}
+
+type targetHealth struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	Breaker     string    `json:"breaker,omitempty"`
+	Paused      bool      `json:"paused,omitempty"`
+}
+
+// snapshot reports the current state and whether it is healthy.
+func (h *healthState) snapshot() (targetHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	th := targetHealth{Name: h.name, Status: "ok", LastSuccess: h.lastSuccess, Breaker: h.breaker, Paused: h.paused}
+	if h.lastError != nil {
+		th.LastError = h.lastError.Error()
+	}
+	if h.paused {
+		th.Status = "paused"
+		return th, true
+	}
+	healthy := time.Since(h.lastSuccess) <= h.maxAge || time.Since(h.resumed) <= h.maxAge
+	if !healthy {
+		th.Status = "unhealthy"
+	}
+	return th, healthy
+}
+
+// healthGroup aggregates the health of every keepalive target.
+type healthGroup []*healthState
+
+type healthResponse struct {
+	Status  string         `json:"status"`
+	Targets []targetHealth `json:"targets"`
+}
+
+// healthy reports whether every target's last keepalive succeeded recently.
+func (g healthGroup) healthy() bool {
+	for _, h := range g {
+		if _, healthy := h.snapshot(); !healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeHTTP responds 200 when every target's last keepalive succeeded
+// recently and 503 otherwise.
+func (g healthGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Status: "ok"}
+	code := http.StatusOK
+	for _, h := range g {
+		th, healthy := h.snapshot()
+		if !healthy {
+			resp.Status = "unhealthy"
+			code = http.StatusServiceUnavailable
+		}
+		resp.Targets = append(resp.Targets, th)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
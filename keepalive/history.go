@@ -0,0 +1,82 @@
+package keepalive
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultHistorySize = 100
+
+// historyEntry is one recorded keepalive attempt.
+type historyEntry struct {
+	Target    string    `json:"target"`
+	Time      time.Time `json:"time"`
+	LatencyMS float64   `json:"latency_ms"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// history keeps the most recent keepalive attempts in a fixed-size ring
+// buffer so intermittent failures can be inspected without the logs.
+type history struct {
+	mu      sync.Mutex
+	entries []historyEntry
+	next    int
+	full    bool
+}
+
+func newHistory(size int) *history {
+	return &history{entries: make([]historyEntry, size)}
+}
+
+// record stores the outcome of one attempt, overwriting the oldest entry
+// once the buffer is full. It is a no-op on a nil history.
+func (h *history) record(target string, start time.Time, latency time.Duration, err error) {
+	if h == nil {
+		return
+	}
+	e := historyEntry{
+		Target:    target,
+		Time:      start.UTC(),
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+		Result:    "ok",
+	}
+	if err != nil {
+		e.Result = "error"
+		e.Error = err.Error()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// snapshot returns the recorded entries, oldest first.
+func (h *history) snapshot() []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]historyEntry(nil), h.entries[:h.next]...)
+	}
+	return append(append([]historyEntry(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+type historyResponse struct {
+	Entries []historyEntry `json:"entries"`
+}
+
+// ServeHTTP responds with the recorded attempts as JSON, oldest first.
+func (h *history) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	entries := h.snapshot()
+	if entries == nil {
+		entries = []historyEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(historyResponse{Entries: entries})
+}
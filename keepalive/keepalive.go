@@ -0,0 +1,438 @@
+// Package keepalive keeps Couchbase clusters active by periodically running
+// a lightweight operation against them. It backs the couchbase-keepalive
+// command and can be embedded in other services.
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keepalive keeps every cluster of a Config alive. Create one with New, then
+// either Start it and later Stop it, or run a single pass with RunOnce,
+// Check or Reset followed by Stop.
+type Keepalive struct {
+	cfg    Config
+	conns  []*clusterConn
+	health healthGroup
+	status *statusFile
+	// history is nil when HistorySize is zero.
+	history *history
+	// webhook is nil unless FailureWebhookURL is set.
+	webhook *webhook
+	// output is nil unless OutputFormat is ndjson.
+	output *heartbeatStream
+	// tracing is nil unless an OTLP endpoint is configured.
+	tracing *sdktrace.TracerProvider
+	// partial is set when some configured cluster could not be connected.
+	partial bool
+	pause   *pauseControl
+	stats   *runStats
+
+	// failed receives the first error of a target that gave up after
+	// MaxConsecutiveFailures.
+	failed chan error
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// errPartialConnect is reported by single-pass operations when some
+// configured cluster could not be connected.
+var errPartialConnect = errors.New("one or more clusters could not be connected")
+
+// New validates cfg and connects to every cluster and bucket it describes,
+// retrying as configured until ctx is done. Clusters that fail to connect
+// are logged and skipped; New only fails when none can be connected.
+func New(ctx context.Context, cfg Config) (*Keepalive, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	// LoadConfig has already expanded this, but a Config built in code may not have.
+	instance, err := expandHostname(cfg.InstanceLabel)
+	if err != nil {
+		return nil, fmt.Errorf("instance label: %w (INSTANCE_LABEL)", err)
+	}
+	cfg.InstanceLabel = instance
+	logBanner(cfg)
+	warnIgnoredProxy()
+
+	k := &Keepalive{cfg: cfg, failed: make(chan error, 1), pause: &pauseControl{}, stats: &runStats{}}
+	if cfg.StatusFile != "" {
+		k.status = newStatusFile(cfg.StatusFile)
+	}
+	if cfg.HistorySize > 0 {
+		k.history = newHistory(cfg.HistorySize)
+	}
+	if cfg.FailureWebhookURL != "" {
+		k.webhook = newWebhook(cfg.FailureWebhookURL, cfg.WebhookDebounce)
+	}
+	if strings.ToLower(cfg.OutputFormat) == outputNDJSON {
+		k.output = newHeartbeatStream(os.Stdout)
+	}
+	var tracer trace.Tracer
+	if cfg.OTLPEndpoint != "" {
+		provider, err := newTracerProvider(context.Background(), cfg.OTLPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		k.tracing = provider
+		tracer = provider.Tracer(tracerName)
+		slog.Info("Tracing keepalives", "endpoint", cfg.OTLPEndpoint)
+	}
+	clusters := cfg.clusterConfigs()
+	for _, cc := range clusters {
+		// validate has already checked the connection string.
+		spec, _ := parseConnectionString(cc.ConnectionString)
+		slog.Debug("Connecting to cluster",
+			"cluster", cc.Name,
+			"connection_string", sanitizeConnectionString(cc.ConnectionString),
+			"scheme", spec.scheme,
+		)
+		warnInsecureTLS(cc, spec.scheme)
+	}
+	// Clusters and buckets connect in parallel, so one that never becomes
+	// ready only delays startup by its own ready timeout.
+	conns, errs := connectAll(ctx, clusters)
+	for i, cc := range clusters {
+		conn, err := conns[i], errs[i]
+		if err != nil {
+			slog.Error("Failed to connect to cluster", "cluster", cc.Name, "err", err)
+			setConnected(cc.Name, false)
+			k.partial = true
+			continue
+		}
+		if err := conn.buildLoops(k.status, tracer, k.history); err != nil {
+			slog.Error("Invalid configuration", "cluster", cc.Name, "err", err)
+			closeCluster(conn.cluster)
+			k.partial = true
+			continue
+		}
+		setConnected(cc.Name, true)
+		slog.Info("Connected to cluster", "cluster", cc.Name, "bucket", cc.BucketName, "targets", len(conn.loops))
+		for _, l := range conn.loops {
+			l.giveUp = k.giveUp
+			l.webhook = k.webhook
+			l.output = k.output
+			l.pause = k.pause
+			l.stats = k.stats
+			k.health = append(k.health, l.health)
+		}
+		conn.stats = k.stats
+		k.conns = append(k.conns, conn)
+	}
+	if len(k.conns) == 0 {
+		k.shutdownTracing()
+		return nil, errors.New("no cluster could be connected")
+	}
+	k.pause.health = k.health
+	return k, nil
+}
+
+// connectAll connects to every cluster in parallel and returns the
+// connections and errors in the order of clusters.
+func connectAll(ctx context.Context, clusters []Config) ([]*clusterConn, []error) {
+	conns := make([]*clusterConn, len(clusters))
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for i, cc := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conns[i], errs[i] = connectWithRetry(ctx, cc)
+		}()
+	}
+	wg.Wait()
+	return conns, errs
+}
+
+// Start runs the keepalive loops, the admin server and the status file
+// writer in the background until ctx is cancelled or Stop is called.
+func (k *Keepalive) Start(ctx context.Context) error {
+	if k.cancel != nil {
+		return errors.New("keepalive already started")
+	}
+	ctx, k.cancel = context.WithCancel(ctx)
+	k.stats.started = time.Now()
+
+	if k.cfg.MetricsListenAddr != "" {
+		k.wg.Add(1)
+		go func() {
+			defer k.wg.Done()
+			serveAdmin(ctx, k.cfg.MetricsListenAddr, k.cfg.InstanceLabel, k.cfg.AdminToken, k.health, k.pause, k.history, k.cfg.EnablePprof)
+		}()
+	}
+	if k.cfg.GRPCHealthListenAddr != "" {
+		k.wg.Add(1)
+		go func() {
+			defer k.wg.Done()
+			serveGRPCHealth(ctx, k.cfg.GRPCHealthListenAddr, k.health)
+		}()
+	}
+	if k.cfg.UptimeLogInterval > 0 {
+		k.wg.Add(1)
+		go func() {
+			defer k.wg.Done()
+			logUptime(ctx, k.cfg.UptimeLogInterval)
+		}()
+	}
+	if k.status != nil {
+		k.wg.Add(1)
+		go func() {
+			defer k.wg.Done()
+			k.status.run(ctx)
+		}()
+	}
+	if k.webhook != nil {
+		k.wg.Add(1)
+		go func() {
+			defer k.wg.Done()
+			k.webhook.run(ctx)
+		}()
+	}
+	for _, conn := range k.conns {
+		if conn.cfg.TopologyPollInterval > 0 {
+			k.wg.Add(1)
+			go func() {
+				defer k.wg.Done()
+				conn.observeTopology(ctx, conn.cfg.TopologyPollInterval)
+			}()
+		}
+		if conn.cfg.CollectionRefresh > 0 {
+			k.wg.Add(1)
+			go func() {
+				defer k.wg.Done()
+				conn.refreshCollections(ctx, conn.cfg.CollectionRefresh)
+			}()
+		}
+		if conn.cfg.DiscoveryInterval > 0 && conn.cfg.discoversCollections() {
+			k.wg.Add(1)
+			go func() {
+				defer k.wg.Done()
+				conn.discoverCollections(ctx, conn.cfg.DiscoveryInterval)
+			}()
+		}
+		for _, l := range conn.loops {
+			k.wg.Add(1)
+			go func() {
+				defer k.wg.Done()
+				l.run(ctx)
+			}()
+		}
+	}
+	return nil
+}
+
+// Failed returns a channel that receives an error once any target has
+// failed MaxConsecutiveFailures keepalives in a row. The keepalive keeps
+// running; it is up to the caller to Stop it.
+func (k *Keepalive) Failed() <-chan error {
+	return k.failed
+}
+
+// Pause stops running keepalives, e.g. for a maintenance window, until
+// Resume is called. The loops keep their schedule and /healthz reports the
+// targets as paused. It is also available as POST /pause on the admin server.
+func (k *Keepalive) Pause() {
+	k.pause.set(true)
+}
+
+// Resume undoes Pause; keepalives continue on the next tick. It is also
+// available as POST /resume on the admin server.
+func (k *Keepalive) Resume() {
+	k.pause.set(false)
+}
+
+// giveUp reports err on the failed channel unless an error is already pending.
+func (k *Keepalive) giveUp(err error) {
+	select {
+	case k.failed <- err:
+	default:
+	}
+}
+
+// Stop stops everything Start started, waits for it to finish, logs a
+// summary of the run when Start was called and closes every cluster
+// connection. If that takes longer than the configured
+// shutdown timeout it returns an error and leaves the rest running in the
+// background.
+func (k *Keepalive) Stop() error {
+	if k.cancel != nil {
+		k.cancel()
+	}
+	finished := runWithTimeout(k.cfg.ShutdownTimeout, func() {
+		k.wg.Wait()
+		if k.cancel != nil {
+			k.logSummary()
+		}
+		closeAll(k.conns)
+		k.shutdownTracing()
+	})
+	if !finished {
+		return fmt.Errorf("shutdown timed out after %s", k.cfg.ShutdownTimeout)
+	}
+	return nil
+}
+
+// shutdownTracing flushes any buffered spans and stops the exporter.
+func (k *Keepalive) shutdownTracing() {
+	if k.tracing == nil {
+		return
+	}
+	if err := k.tracing.Shutdown(context.Background()); err != nil {
+		slog.Warn("Error shutting down tracing", "err", err)
+	}
+}
+
+// RunOnce runs every keepalive a single time without retries.
+func (k *Keepalive) RunOnce(ctx context.Context) error {
+	var errs []error
+	for _, conn := range k.conns {
+		if !conn.pingOnce(ctx) {
+			errs = append(errs, fmt.Errorf("keepalive failed for cluster %q", conn.cfg.Name))
+		}
+	}
+	return k.result(errs)
+}
+
+// RunForeground runs every keepalive on the calling goroutine, straight
+// away and then on every tick of the shortest configured interval, logging
+// each outcome, until ctx is done. It is meant for interactive debugging:
+// nothing else Start would run, such as the admin server, is started. It
+// returns the error once a target has failed MaxConsecutiveFailures times.
+func (k *Keepalive) RunForeground(ctx context.Context) error {
+	var loops []*loop
+	interval := time.Duration(0)
+	for _, conn := range k.conns {
+		for _, l := range conn.loops {
+			loops = append(loops, l)
+			if interval == 0 || l.interval < interval {
+				interval = l.interval
+			}
+		}
+	}
+	if len(loops) == 0 {
+		return k.result(nil)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for tick := 1; ; tick++ {
+		slog.Info("Tick", "tick", tick, "targets", len(loops))
+		for _, l := range loops {
+			skipped := l.tick(ctx)
+			if ctx.Err() != nil {
+				return nil
+			}
+			switch {
+			case skipped != "":
+				slog.Info("Keepalive skipped", "target", l.name, "reason", skipped)
+			case !l.failing:
+				slog.Info("Keepalive succeeded", "target", l.name)
+			}
+		}
+		select {
+		case err := <-k.failed:
+			return err
+		default:
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Check verifies that every target collection resolves, without writing.
+func (k *Keepalive) Check() error {
+	var errs []error
+	for _, conn := range k.conns {
+		if err := conn.check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return k.result(errs)
+}
+
+// SelfTest writes a marker document to every target collection, reads it
+// back, checks it matches and deletes it.
+func (k *Keepalive) SelfTest() error {
+	var errs []error
+	for _, conn := range k.conns {
+		if err := conn.selfTest(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return k.result(errs)
+}
+
+// Reset deletes the counter document in every target collection.
+func (k *Keepalive) Reset() error {
+	var errs []error
+	for _, conn := range k.conns {
+		if err := conn.resetCounters(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return k.result(errs)
+}
+
+// result joins errs with errPartialConnect when a cluster was skipped.
+func (k *Keepalive) result(errs []error) error {
+	if k.partial {
+		errs = append(errs, errPartialConnect)
+	}
+	return errors.Join(errs...)
+}
+
+// Reload applies the reloadable settings of next, such as the interval, to
+// the running loops. Other changes are logged as requiring a restart.
+func (k *Keepalive) Reload(next Config) {
+	k.cfg = reload(k.cfg, next, k.conns)
+}
+
+// Clusters reports how many clusters are connected.
+func (k *Keepalive) Clusters() int {
+	return len(k.conns)
+}
+
+// runWithTimeout runs fn in a goroutine and reports whether it returned
+// within timeout. If it did not, fn keeps running in the background.
+func runWithTimeout(timeout time.Duration, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// expandHostname replaces any {hostname} placeholder in s with the local hostname.
+func expandHostname(s string) (string, error) {
+	if !strings.Contains(s, "{hostname}") {
+		return s, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("resolve hostname: %w", err)
+	}
+	return strings.ReplaceAll(s, "{hostname}", hostname), nil
+}
@@ -0,0 +1,37 @@
+package keepalive
+
+import "time"
+
+const (
+	defaultSlowThreshold = 500 * time.Millisecond
+	// latencyWindow is how many recent attempts the rolling average covers,
+	// and how often, in attempts, it is logged.
+	latencyWindow = 10
+)
+
+// rollingAverage is the mean of the last latencyWindow latencies.
+type rollingAverage struct {
+	samples [latencyWindow]time.Duration
+	count   int
+}
+
+// add records d and reports whether a full window has passed since the
+// last report.
+func (r *rollingAverage) add(d time.Duration) bool {
+	r.samples[r.count%latencyWindow] = d
+	r.count++
+	return r.count%latencyWindow == 0
+}
+
+// mean returns the average of the recorded samples.
+func (r *rollingAverage) mean() time.Duration {
+	n := min(r.count, latencyWindow)
+	if n == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range r.samples[:n] {
+		total += d
+	}
+	return total / time.Duration(n)
+}
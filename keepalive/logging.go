@@ -0,0 +1,121 @@
+package keepalive
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultLogMaxSizeMB  = 100
+	defaultLogMaxBackups = 3
+)
+
+const (
+	timestampsLocal = "local"
+	timestampsUTC   = "utc"
+	timestampsNone  = "none"
+)
+
+// parseLogLevel parses one of debug, info, warn or error.
+func parseLogLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn or error (LOG_LEVEL)", level)
+	}
+	return lvl, nil
+}
+
+// checkLogFormat reports whether format is text or json.
+func checkLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "text", "json":
+		return nil
+	}
+	return fmt.Errorf("invalid log format %q: must be text or json (LOG_FORMAT)", format)
+}
+
+// checkLogTimestamps reports whether timestamps is local, utc or none.
+func checkLogTimestamps(timestamps string) error {
+	switch strings.ToLower(timestamps) {
+	case timestampsLocal, timestampsUTC, timestampsNone:
+		return nil
+	}
+	return fmt.Errorf("invalid log timestamps %q: must be %s, %s or %s (LOG_TIMESTAMPS)", timestamps, timestampsLocal, timestampsUTC, timestampsNone)
+}
+
+// NewLogger builds a slog.Logger writing to w. format is "text" or "json",
+// level is one of debug, info, warn or error, and timestamps is one of
+// local, utc or none. With caller set, every record carries the file:line
+// it was logged from.
+func NewLogger(w io.Writer, format, level, timestamps string, caller bool) (*slog.Logger, error) {
+	lvl, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLogFormat(format); err != nil {
+		return nil, err
+	}
+	if err := checkLogTimestamps(timestamps); err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{
+		Level:       lvl,
+		AddSource:   caller,
+		ReplaceAttr: replaceLogAttr(strings.ToLower(timestamps)),
+	}
+	if strings.ToLower(format) == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), nil
+}
+
+// replaceLogAttr rewrites the time of every record for timestamps, which is
+// utc or none to log it in UTC or not at all, and shortens the source to
+// the file name so the caller reads as file:line.
+func replaceLogAttr(timestamps string) func([]string, slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 {
+			return a
+		}
+		switch a.Key {
+		case slog.TimeKey:
+			switch timestamps {
+			case timestampsUTC:
+				return slog.Time(a.Key, a.Value.Time().UTC())
+			case timestampsNone:
+				return slog.Attr{}
+			}
+		case slog.SourceKey:
+			if src, ok := a.Value.Any().(*slog.Source); ok {
+				return slog.String(a.Key, fmt.Sprintf("%s:%d", filepath.Base(src.File), src.Line))
+			}
+		}
+		return a
+	}
+}
+
+// LogOutput returns where logs should be written: a size-rotated LogFile
+// when one is configured, otherwise stderr. Closing it closes the file and
+// leaves stderr open.
+func LogOutput(cfg Config) io.WriteCloser {
+	if cfg.LogFile == "" {
+		return nopCloser{os.Stderr}
+	}
+	return &lumberjack.Logger{
+		Filename:   cfg.LogFile,
+		MaxSize:    cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
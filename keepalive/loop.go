@@ -0,0 +1,357 @@
+package keepalive
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// loop periodically runs a strategy to keep the cluster active.
+type loop struct {
+	name     string
+	interval time.Duration
+	// ownSchedule is set for the loops of an entry under targets, whose
+	// interval a reload of the cluster's settings leaves alone.
+	ownSchedule bool
+	// failureInterval, when non-zero, replaces interval while the last tick
+	// failed, so recovery is noticed sooner.
+	failureInterval time.Duration
+	failing         bool
+	retry           retryPolicy
+	health          *healthState
+	history         *history
+	webhook         *webhook
+	output          *heartbeatStream
+	reset           chan time.Duration
+
+	// ramp, while non-zero, replaces interval during the warm-up after
+	// startup. It is multiplied by rampFactor after every tick until it
+	// reaches interval or rampWindow has passed since the loop started.
+	ramp       time.Duration
+	rampFactor float64
+	rampWindow time.Duration
+	rampUntil  time.Time
+
+	// slowThreshold, when non-zero, is the latency above which an attempt
+	// is logged as slow. latency is only touched by the run goroutine.
+	slowThreshold time.Duration
+	latency       rollingAverage
+
+	// startupJitter is the upper bound of a random delay before the first
+	// tick, and fireOnStart runs one keepalive straight after it.
+	startupJitter time.Duration
+	fireOnStart   bool
+
+	// concurrency is how many pings every attempt runs at once.
+	concurrency int
+
+	// kind is the configured strategy name, and tracer, when set, wraps
+	// every attempt in a span.
+	kind   string
+	tracer trace.Tracer
+
+	// conn, when set, is rebuilt after reconnectAfter consecutive failed
+	// ticks. Zero disables reconnecting.
+	conn           *clusterConn
+	reconnectAfter int
+	failures       int
+
+	// giveUp, when set, is called once consecutive failed ticks reach
+	// maxFailures. Unlike failures, consecutive survives reconnects.
+	maxFailures int
+	consecutive int
+	giveUp      func(error)
+
+	// breaker, when set, skips ticks while the target keeps failing.
+	breaker *breaker
+
+	// warm, when set, re-warms the cluster's service connections after
+	// every tick.
+	warm func(context.Context)
+
+	// pause, when set, makes ticks a no-op while keepalives are paused.
+	pause *pauseControl
+
+	// stats, when set, counts every attempt for the shutdown summary.
+	stats *runStats
+
+	// mu guards strategy and generation, which change on reconnect.
+	mu         sync.Mutex
+	strategy   KeepaliveStrategy
+	generation int
+}
+
+// run pings the strategy on every tick of interval until ctx is cancelled.
+// Failed pings are retried according to retry before waiting for the next tick.
+func (k *loop) run(ctx context.Context) {
+	if k.startupJitter > 0 {
+		delay := rand.N(k.startupJitter)
+		slog.Debug("Delaying first keepalive", "target", k.name, "delay", delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+	if k.fireOnStart {
+		k.tick(ctx)
+	}
+
+	if k.ramp >= k.interval {
+		k.ramp = 0
+	}
+	if k.ramp > 0 {
+		k.rampUntil = time.Now().Add(k.rampWindow)
+		slog.Info("Warming up", "target", k.name, "interval", k.ramp, "factor", k.rampFactor, "window", k.rampWindow)
+	}
+	ticker := time.NewTicker(k.currentInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			failing := k.failing
+			k.tick(ctx)
+			if k.advanceRamp() {
+				ticker.Reset(k.currentInterval())
+			} else if k.failing != failing && k.failureInterval > 0 {
+				ticker.Reset(k.currentInterval())
+				slog.Debug("Keepalive interval switched", "target", k.name, "failing", k.failing, "interval", k.currentInterval())
+			}
+		case interval := <-k.reset:
+			k.interval = interval
+			ticker.Reset(k.currentInterval())
+			slog.Info("Keepalive interval updated", "target", k.name, "interval", interval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// currentInterval returns the tick interval for the last outcome, or the
+// warm-up interval while that is shorter.
+func (k *loop) currentInterval() time.Duration {
+	if k.failing && k.failureInterval > 0 {
+		return k.failureInterval
+	}
+	if k.ramp > 0 && k.ramp < k.interval {
+		return k.ramp
+	}
+	return k.interval
+}
+
+// advanceRamp grows the warm-up interval after a tick, ending the warm-up
+// once it reaches interval or the window has passed. It reports whether the
+// ticker needs resetting.
+func (k *loop) advanceRamp() bool {
+	if k.ramp == 0 {
+		return false
+	}
+	k.ramp = time.Duration(float64(k.ramp) * k.rampFactor)
+	if k.ramp >= k.interval || !time.Now().Before(k.rampUntil) {
+		k.ramp = 0
+		slog.Info("Warm-up finished", "target", k.name, "interval", k.interval)
+	} else {
+		slog.Debug("Warm-up interval increased", "target", k.name, "interval", k.ramp)
+	}
+	return true
+}
+
+// Reasons tick skips a keepalive.
+const (
+	skipPaused      = "paused"
+	skipBreakerOpen = "circuit breaker open"
+)
+
+// tick runs one keepalive with retries and records its outcome. It returns
+// why it skipped the keepalive instead, or "" when it ran it.
+func (k *loop) tick(ctx context.Context) (skipped string) {
+	if k.pause.isPaused() {
+		slog.Debug("Keepalives paused, skipping keepalive", "target", k.name)
+		return skipPaused
+	}
+	if !k.breaker.allow() {
+		slog.Debug("Circuit breaker open, skipping keepalive", "target", k.name)
+		return skipBreakerOpen
+	}
+	// A half-open breaker allows a single probe, so it is not retried.
+	retry := k.retry
+	if k.breaker.probing() {
+		retry.maxRetries = 0
+	}
+	strategy, generation := k.current()
+	var start time.Time
+	var elapsed time.Duration
+	err := retry.do(ctx, k.name, func() error {
+		return observeKeepalive(func() error {
+			// Each attempt gets its own context, so nothing it started
+			// outlives it and shutdown interrupts it mid-operation.
+			attemptCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			ping := strategy.Ping
+			if k.concurrency > 1 {
+				ping = pingConcurrently(k.name, k.concurrency, strategy)
+			}
+			start = time.Now()
+			err := traceKeepalive(attemptCtx, k.tracer, k.name, k.kind, ping)
+			elapsed = time.Since(start)
+			k.history.record(k.name, start, elapsed, err)
+			k.stats.attempt(err)
+			k.observeLatency(elapsed)
+			return err
+		})
+	})
+	if ctx.Err() != nil {
+		return ""
+	}
+	if k.warm != nil {
+		k.warm(ctx)
+	}
+	k.webhook.observe(k.name, err)
+	if k.output != nil {
+		var counters *counterValues
+		if k.conn != nil {
+			counters = k.conn.counters
+		}
+		k.output.write(k.name, start, elapsed, counters.take(k.name), err)
+	}
+	k.breaker.record(err)
+	k.health.setBreaker(k.breaker.current())
+	k.failing = err != nil
+	if err != nil {
+		k.health.recordFailure(err)
+		slog.Error("Keepalive error", "target", k.name, "category", errorCategory(err), "err", err)
+		k.failures++
+		k.consecutive++
+		if k.giveUp != nil && k.maxFailures > 0 && k.consecutive >= k.maxFailures {
+			k.giveUp(fmt.Errorf("%s failed %d consecutive keepalives: %w", k.name, k.consecutive, err))
+		}
+		if k.conn != nil && k.reconnectAfter > 0 && k.failures >= k.reconnectAfter {
+			if k.conn.reconnect(ctx, generation, err) == nil {
+				k.failures = 0
+			}
+		}
+		return ""
+	}
+	k.health.recordSuccess()
+	k.failures = 0
+	k.consecutive = 0
+	return ""
+}
+
+// observeLatency logs the latency of one attempt, warns when it exceeds the
+// slow threshold and periodically logs the rolling average.
+func (k *loop) observeLatency(elapsed time.Duration) {
+	slog.Debug("Keepalive attempt finished", "target", k.name, "latency", elapsed)
+	if k.slowThreshold > 0 && elapsed > k.slowThreshold {
+		slog.Warn("Slow keepalive", "target", k.name, "latency", elapsed, "threshold", k.slowThreshold)
+	}
+	if k.latency.add(elapsed) {
+		slog.Info("Keepalive latency", "target", k.name, "average", k.latency.mean(), "window", latencyWindow)
+	}
+}
+
+// current returns the strategy in use and the connection generation it is bound to.
+func (k *loop) current() (KeepaliveStrategy, int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.strategy, k.generation
+}
+
+// setStrategy rebinds the keepalive to a strategy on a new connection.
+func (k *loop) setStrategy(strategy KeepaliveStrategy, generation int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.strategy, k.generation = strategy, generation
+}
+
+// setInterval changes the tick interval of a running loop, replacing any
+// update it has not picked up yet.
+func (k *loop) setInterval(interval time.Duration) {
+	select {
+	case <-k.reset:
+	default:
+	}
+	k.reset <- interval
+}
+
+// retryPolicy retries a failed operation with exponential backoff and jitter.
+type retryPolicy struct {
+	maxRetries int
+	maxDelay   time.Duration
+}
+
+// do runs op, retrying up to maxRetries times on a retryable failure. The
+// delay starts at initialRetryDelay and doubles on each attempt up to
+// maxDelay, with up to 25% random jitter added. It returns early with
+// ctx.Err() if ctx is cancelled while waiting.
+func (p retryPolicy) do(ctx context.Context, name string, op func() error) error {
+	err := op()
+	delay := initialRetryDelay
+	for attempt := 1; err != nil && isRetryable(err) && attempt <= p.maxRetries; attempt++ {
+		wait := delay + rand.N(delay/4+1)
+		slog.Warn("Keepalive failed, retrying", "target", name, "category", errorCategory(err), "err", err, "attempt", attempt, "max_retries", p.maxRetries, "backoff", wait.Round(time.Millisecond))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		err = op()
+		delay = min(delay*2, p.maxDelay)
+	}
+	return err
+}
+
+// target identifies a collection to keep alive.
+type target struct {
+	scope      string
+	collection string
+}
+
+// defaultTarget is the bucket's default collection, used when no scope or
+// collection is configured. It is the only collection on servers before 7.0.
+var defaultTarget = target{scope: "_default", collection: "_default"}
+
+func (t target) String() string {
+	return t.scope + "." + t.collection
+}
+
+// in resolves the collection in bucket.
+func (t target) in(bucket *gocb.Bucket) *gocb.Collection {
+	if t == defaultTarget {
+		return bucket.DefaultCollection()
+	}
+	return bucket.Scope(t.scope).Collection(t.collection)
+}
+
+// parseTargets parses a list of scope.collection pairs.
+func parseTargets(entries []string) ([]target, error) {
+	var targets []target
+	seen := make(map[target]bool)
+	for _, entry := range entries {
+		scope, collection, ok := strings.Cut(entry, ".")
+		if !ok || scope == "" || collection == "" || strings.Contains(collection, ".") {
+			return nil, fmt.Errorf("invalid collection %q: want scope.collection (COUCHBASE_COLLECTIONS)", entry)
+		}
+		t := target{scope: scope, collection: collection}
+		if seen[t] {
+			return nil, fmt.Errorf("duplicate collection %q (COUCHBASE_COLLECTIONS)", entry)
+		}
+		seen[t] = true
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
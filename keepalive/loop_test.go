@@ -0,0 +1,96 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// countingStrategy fails with the errors in errs in turn, then succeeds,
+// counting every call.
+type countingStrategy struct {
+	errs  []error
+	calls int
+}
+
+func (s *countingStrategy) Ping(context.Context) error {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return s.errs[s.calls-1]
+	}
+	return nil
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"retryable error is retried", []error{gocb.ErrTemporaryFailure}, 2, nil},
+		{"retryable error until out of retries", []error{gocb.ErrTimeout, gocb.ErrTimeout}, 2, gocb.ErrTimeout},
+		{"non-retryable error is not retried", []error{fmt.Errorf("increment: %w", gocb.ErrAuthenticationFailure)}, 1, gocb.ErrAuthenticationFailure},
+		{"missing collection is not retried", []error{gocb.ErrCollectionNotFound}, 1, gocb.ErrCollectionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := &countingStrategy{errs: tt.errs}
+			policy := retryPolicy{maxRetries: 1, maxDelay: initialRetryDelay}
+			err := policy.do(context.Background(), "test", func() error { return strategy.Ping(context.Background()) })
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("do() = %v, want %v", err, tt.wantErr)
+			}
+			if strategy.calls != tt.wantCalls {
+				t.Errorf("strategy called %d times, want %d", strategy.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	strategy := &countingStrategy{errs: []error{gocb.ErrTimeout}}
+	policy := retryPolicy{maxRetries: 3, maxDelay: initialRetryDelay}
+	if err := policy.do(ctx, "test", func() error { return strategy.Ping(ctx) }); !errors.Is(err, context.Canceled) {
+		t.Errorf("do() = %v, want %v", err, context.Canceled)
+	}
+	if strategy.calls != 1 {
+		t.Errorf("strategy called %d times, want 1", strategy.calls)
+	}
+}
+
+func TestTickReportsSkips(t *testing.T) {
+	newLoop := func() *loop {
+		return &loop{
+			name:     "keepalive",
+			strategy: &countingStrategy{},
+			health:   newHealthState("keepalive", time.Minute),
+			pause:    &pauseControl{},
+			breaker:  newBreaker("keepalive", 1, time.Hour),
+		}
+	}
+
+	k := newLoop()
+	if skipped := k.tick(context.Background()); skipped != "" {
+		t.Errorf("tick() skipped with %q, want it to run", skipped)
+	}
+
+	k = newLoop()
+	k.pause.set(true)
+	if skipped := k.tick(context.Background()); skipped != skipPaused {
+		t.Errorf("paused tick() = %q, want %q", skipped, skipPaused)
+	}
+
+	k = newLoop()
+	k.breaker.record(errors.New("down"))
+	if skipped := k.tick(context.Background()); skipped != skipBreakerOpen {
+		t.Errorf("tick() with an open breaker = %q, want %q", skipped, skipBreakerOpen)
+	}
+}
@@ -0,0 +1,207 @@
+package keepalive
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// instanceLabel is the label INSTANCE_LABEL adds to every metric. It is not
+// "instance", which Prometheus sets to the scraped address itself.
+const instanceLabel = "instance_label"
+
+var (
+	keepaliveAttempts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "keepalive_attempts_total",
+		Help: "Total number of keepalive attempts, including retries.",
+	})
+	keepaliveFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "keepalive_failures_total",
+		Help: "Total number of failed keepalive attempts, by error category.",
+	}, []string{"category"})
+	keepaliveLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "keepalive_latency_seconds",
+		Help:    "Duration of each keepalive attempt in seconds.",
+		Buckets: prometheus.DefBuckets,
+	})
+	keepaliveReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "keepalive_reconnects_total",
+		Help: "Total number of attempts to rebuild a cluster connection.",
+	}, []string{"cluster"})
+	keepaliveCredentialRotations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "keepalive_credential_rotations_total",
+		Help: "Total number of times a changed password file was picked up.",
+	}, []string{"cluster"})
+	keepaliveConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "keepalive_connected",
+		Help: "Whether the cluster connection is believed up (1) or down (0).",
+	}, []string{"cluster"})
+	keepaliveServiceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "keepalive_service_up",
+		Help: "Whether every endpoint of a pinged service answered the last ping (1) or not (0).",
+	}, []string{"target", "service"})
+	keepaliveClusterNodes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "keepalive_cluster_nodes",
+		Help: "Number of nodes the SDK last reported connections to.",
+	}, []string{"cluster"})
+	keepaliveTopologyChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "keepalive_topology_changes_total",
+		Help: "Total number of observed changes to the set of cluster nodes.",
+	}, []string{"cluster"})
+	keepaliveReplicasResponding = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "keepalive_replicas_responding",
+		Help: "Number of replicas that answered the last replica read of the counter document.",
+	}, []string{"target"})
+	keepalivePaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "keepalive_paused",
+		Help: "Whether keepalives are paused through POST /pause (1) or running (0).",
+	})
+	keepaliveProcessUptime = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "keepalive_process_uptime_seconds",
+		Help: "Seconds since the process started.",
+	}, func() float64 {
+		return time.Since(processStart).Seconds()
+	})
+	keepaliveCounterValue = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "keepalive_counter_value",
+		Help: "Value of the counter document after the last increment.",
+	}, []string{"target", "doc"})
+)
+
+// boolGauge returns the gauge value for b: 1 when true, 0 when false.
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// setConnected records whether the connection to cluster is up; a
+// connection coming up restarts its age.
+func setConnected(cluster string, up bool) {
+	keepaliveConnected.WithLabelValues(cluster).Set(boolGauge(up))
+	connectionAge.set(cluster, up)
+}
+
+// setServiceUp records the outcome of the last ping of service.
+func setServiceUp(target, service string, up bool) {
+	keepaliveServiceUp.WithLabelValues(target, service).Set(boolGauge(up))
+}
+
+// counterValues remembers the last value of every counter document so a
+// counter that goes backwards, because the document was deleted, flushed or
+// overwritten, is noticed. It survives reconnects.
+type counterValues struct {
+	mu   sync.Mutex
+	last map[[2]string]uint64
+	// fresh holds each target's latest value until take collects it.
+	fresh map[string]uint64
+}
+
+func newCounterValues() *counterValues {
+	return &counterValues{last: make(map[[2]string]uint64), fresh: make(map[string]uint64)}
+}
+
+// observe records value for doc in target and warns when it is lower than
+// the value seen before. It is a no-op on a nil counterValues.
+func (v *counterValues) observe(target, doc string, value uint64) {
+	if v == nil {
+		return
+	}
+	if previous, seen := v.record(target, doc, value); seen && value < previous {
+		slog.Warn("Counter decreased, the document was recreated or modified", "target", target, "doc", doc, "previous", previous, "counter", value)
+	}
+}
+
+// reset records value for doc in target like observe, but without the
+// warning, for a counter that was reset on purpose. It is a no-op on a nil
+// counterValues.
+func (v *counterValues) reset(target, doc string, value uint64) {
+	if v == nil {
+		return
+	}
+	v.record(target, doc, value)
+}
+
+// record stores value for doc in target and returns the value seen before,
+// if any.
+func (v *counterValues) record(target, doc string, value uint64) (previous uint64, seen bool) {
+	keepaliveCounterValue.WithLabelValues(target, doc).Set(float64(value))
+	key := [2]string{target, doc}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous, seen = v.last[key]
+	v.last[key] = value
+	v.fresh[target] = value
+	return previous, seen
+}
+
+// take returns the value target's counter was last observed at and
+// forgets it, so a tick that did not increment reports none. It returns nil
+// on a nil counterValues.
+func (v *counterValues) take(target string) *uint64 {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, ok := v.fresh[target]
+	if !ok {
+		return nil
+	}
+	delete(v.fresh, target)
+	return &value
+}
+
+// observeKeepalive runs op and records its outcome and latency.
+func observeKeepalive(op func() error) error {
+	start := time.Now()
+	err := op()
+	keepaliveLatency.Observe(time.Since(start).Seconds())
+	keepaliveAttempts.Inc()
+	if err != nil {
+		keepaliveFailures.WithLabelValues(errorCategory(err)).Inc()
+	}
+	return err
+}
+
+// metricsHandler serves the default registry, with every metric labelled
+// with instance unless it is empty.
+func metricsHandler(instance string) http.Handler {
+	if instance == "" {
+		return promhttp.Handler()
+	}
+	gatherer := labelledGatherer{prometheus.DefaultGatherer, instance}
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+}
+
+// labelledGatherer adds the instance label to every metric it gathers. The
+// metrics are registered when the package loads, before the label is known,
+// so it is added at scrape time instead.
+type labelledGatherer struct {
+	prometheus.Gatherer
+	instance string
+}
+
+func (g labelledGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	name := instanceLabel
+	for _, family := range families {
+		for _, m := range family.Metric {
+			m.Label = append(m.Label, &dto.LabelPair{Name: &name, Value: &g.instance})
+			slices.SortFunc(m.Label, func(a, b *dto.LabelPair) int {
+				return strings.Compare(a.GetName(), b.GetName())
+			})
+		}
+	}
+	return families, err
+}
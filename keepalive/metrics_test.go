@@ -0,0 +1,30 @@
+package keepalive
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestCounterValuesWarnsOnlyOnUnexpectedDecrease(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	v := newCounterValues()
+	v.observe("keepalive", "counter", 10)
+	v.reset("keepalive", "counter", 0)
+	if strings.Contains(logs.String(), "Counter decreased") {
+		t.Fatalf("reset logged a decrease: %s", logs.String())
+	}
+	if got := v.take("keepalive"); got == nil || *got != 0 {
+		t.Fatalf("take() = %v, want 0", got)
+	}
+
+	v.observe("keepalive", "counter", 5)
+	v.observe("keepalive", "counter", 2)
+	if !strings.Contains(logs.String(), "Counter decreased") {
+		t.Errorf("observe did not log a decrease from 5 to 2: %s", logs.String())
+	}
+}
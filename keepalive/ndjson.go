@@ -0,0 +1,73 @@
+package keepalive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	outputText   = "text"
+	outputNDJSON = "ndjson"
+)
+
+// checkOutputFormat reports whether format is text or ndjson.
+func checkOutputFormat(format string) error {
+	switch strings.ToLower(format) {
+	case outputText, outputNDJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q: must be %s or %s (OUTPUT_FORMAT)", format, outputText, outputNDJSON)
+}
+
+// heartbeatLine is one tick of one target in the NDJSON heartbeat stream.
+type heartbeatLine struct {
+	Time      time.Time `json:"time"`
+	Target    string    `json:"target"`
+	Result    string    `json:"result"`
+	LatencyMS float64   `json:"latency_ms"`
+	Counter   *uint64   `json:"counter,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// heartbeatStream writes one JSON line per tick, for log collectors that
+// ingest stdout directly. It is separate from the human-readable log, which
+// goes to stderr or LOG_FILE.
+type heartbeatStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newHeartbeatStream(w io.Writer) *heartbeatStream {
+	return &heartbeatStream{enc: json.NewEncoder(w)}
+}
+
+// write records the outcome of a tick: when it started, the latency of its
+// last attempt and, after an increment, the counter value. It is a no-op on
+// a nil heartbeatStream.
+func (s *heartbeatStream) write(target string, start time.Time, latency time.Duration, counter *uint64, err error) {
+	if s == nil {
+		return
+	}
+	line := heartbeatLine{
+		Time:      start.UTC(),
+		Target:    target,
+		Result:    "ok",
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+		Counter:   counter,
+	}
+	if err != nil {
+		line.Result = "error"
+		line.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(line); err != nil {
+		slog.Warn("Could not write heartbeat", "target", target, "err", err)
+	}
+}
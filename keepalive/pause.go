@@ -0,0 +1,57 @@
+package keepalive
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+)
+
+// pauseControl switches every keepalive loop off and on again, e.g. for a
+// maintenance window. Paused loops skip their ticks but keep running, so
+// they still stop on cancellation and resume on the next tick.
+type pauseControl struct {
+	paused atomic.Bool
+	health healthGroup
+}
+
+// isPaused reports whether keepalives are paused. A nil pauseControl is
+// never paused.
+func (p *pauseControl) isPaused() bool {
+	return p != nil && p.paused.Load()
+}
+
+// set pauses or resumes the keepalives and reports whether that changed
+// anything.
+func (p *pauseControl) set(paused bool) bool {
+	if !p.paused.CompareAndSwap(!paused, paused) {
+		return false
+	}
+	for _, h := range p.health {
+		h.setPaused(paused)
+	}
+	keepalivePaused.Set(boolGauge(paused))
+	if paused {
+		slog.Info("Keepalives paused")
+	} else {
+		slog.Info("Keepalives resumed")
+	}
+	return true
+}
+
+type pauseResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// ServeHTTP pauses the keepalives on POST /pause and resumes them on
+// POST /resume, responding with the resulting state.
+func (p *pauseControl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p.set(r.URL.Path == "/pause")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pauseResponse{Paused: p.isPaused()})
+}
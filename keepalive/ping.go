@@ -0,0 +1,113 @@
+package keepalive
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const strategyPing = "ping"
+
+var defaultPingServices = []string{"kv"}
+
+// serviceTypes maps config names to the services the diagnostics API can ping.
+var serviceTypes = map[string]gocb.ServiceType{
+	"kv":         gocb.ServiceTypeKeyValue,
+	"query":      gocb.ServiceTypeQuery,
+	"search":     gocb.ServiceTypeSearch,
+	"analytics":  gocb.ServiceTypeAnalytics,
+	"views":      gocb.ServiceTypeViews,
+	"management": gocb.ServiceTypeManagement,
+}
+
+// parseServiceTypes resolves service names such as "kv" or "query" read
+// from the environment variable envKey.
+func parseServiceTypes(names []string, envKey string) ([]gocb.ServiceType, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one service is required (%s)", envKey)
+	}
+	var services []gocb.ServiceType
+	for _, name := range names {
+		service, ok := serviceTypes[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid ping service %q: must be one of %s (%s)", name, strings.Join(serviceNames(), ", "), envKey)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// serviceNames lists the supported service names in sorted order.
+func serviceNames() []string {
+	names := make([]string, 0, len(serviceTypes))
+	for name := range serviceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serviceName returns the config name of service.
+func serviceName(service gocb.ServiceType) string {
+	for name, s := range serviceTypes {
+		if s == service {
+			return name
+		}
+	}
+	return fmt.Sprintf("service(%d)", service)
+}
+
+// pingStrategy pings services through the bucket's diagnostics API, with no
+// data-plane side effects.
+type pingStrategy struct {
+	name     string
+	bucket   *gocb.Bucket
+	services []gocb.ServiceType
+	timeout  time.Duration
+}
+
+func (s pingStrategy) Ping(ctx context.Context) error {
+	result, err := s.bucket.Ping(&gocb.PingOptions{
+		ServiceTypes: s.services,
+		Timeout:      s.timeout,
+		Context:      ctx,
+	})
+	if err != nil {
+		return err
+	}
+	return checkPingResult(s.name, s.services, result)
+}
+
+// checkPingResult records whether every one of services is up in result and
+// fails when any is not. A service is down when one of its endpoints did not
+// answer, or when the ping reported no endpoint for it at all, e.g. because
+// no node runs it.
+func checkPingResult(target string, services []gocb.ServiceType, result *gocb.PingResult) error {
+	var failed []string
+	for _, service := range services {
+		name := serviceName(service)
+		endpoints := result.Services[service]
+		up := len(endpoints) > 0
+		if !up {
+			failed = append(failed, name+": no endpoints")
+		}
+		for _, endpoint := range endpoints {
+			slog.Debug("Ping", "target", target, "service", name, "remote", endpoint.Remote, "latency", endpoint.Latency, "state", endpoint.State)
+			if endpoint.State != gocb.PingStateOk {
+				failed = append(failed, fmt.Sprintf("%s@%s: %s", name, endpoint.Remote, endpoint.Error))
+				up = false
+			}
+		}
+		setServiceUp(target, name, up)
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("ping failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
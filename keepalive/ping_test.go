@@ -0,0 +1,61 @@
+package keepalive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCheckPingResult(t *testing.T) {
+	kv := []gocb.EndpointPingReport{{Remote: "db1:11210", State: gocb.PingStateOk}}
+	tests := []struct {
+		name     string
+		services []gocb.ServiceType
+		result   map[gocb.ServiceType][]gocb.EndpointPingReport
+		wantErr  string
+		wantUp   map[string]float64
+	}{
+		{
+			name:     "all up",
+			services: []gocb.ServiceType{gocb.ServiceTypeKeyValue},
+			result:   map[gocb.ServiceType][]gocb.EndpointPingReport{gocb.ServiceTypeKeyValue: kv},
+			wantUp:   map[string]float64{"kv": 1},
+		},
+		{
+			name:     "endpoint down",
+			services: []gocb.ServiceType{gocb.ServiceTypeKeyValue},
+			result: map[gocb.ServiceType][]gocb.EndpointPingReport{gocb.ServiceTypeKeyValue: {
+				{Remote: "db1:11210", State: gocb.PingStateOk},
+				{Remote: "db2:11210", State: gocb.PingStateTimeout, Error: "timeout"},
+			}},
+			wantErr: "kv@db2:11210",
+			wantUp:  map[string]float64{"kv": 0},
+		},
+		{
+			name:     "service without endpoints",
+			services: []gocb.ServiceType{gocb.ServiceTypeKeyValue, gocb.ServiceTypeQuery},
+			result:   map[gocb.ServiceType][]gocb.EndpointPingReport{gocb.ServiceTypeKeyValue: kv},
+			wantErr:  "query: no endpoints",
+			wantUp:   map[string]float64{"kv": 1, "query": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "test/" + tt.name
+			err := checkPingResult(target, tt.services, &gocb.PingResult{Services: tt.result})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkPingResult: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkPingResult error = %v, want it to mention %q", err, tt.wantErr)
+			}
+			for service, want := range tt.wantUp {
+				if got := testutil.ToFloat64(keepaliveServiceUp.WithLabelValues(target, service)); got != want {
+					t.Errorf("service %s up = %v, want %v", service, got, want)
+				}
+			}
+		})
+	}
+}
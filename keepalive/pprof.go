@@ -0,0 +1,23 @@
+package keepalive
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofPrefix is where the profiling endpoints are served. pprof.Index
+// only resolves profile names under this exact prefix.
+const pprofPrefix = "/debug/pprof/"
+
+// mountPprof serves the runtime profiles under pprofPrefix on mux behind
+// guard. They reveal internals and cost CPU while profiling, so they are
+// only mounted with ENABLE_PPROF.
+func mountPprof(mux *http.ServeMux, guard adminGuard) {
+	mux.Handle(pprofPrefix, guard.wrap(http.HandlerFunc(pprof.Index)))
+	mux.Handle(pprofPrefix+"cmdline", guard.wrap(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle(pprofPrefix+"profile", guard.wrap(http.HandlerFunc(pprof.Profile)))
+	mux.Handle(pprofPrefix+"symbol", guard.wrap(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle(pprofPrefix+"trace", guard.wrap(http.HandlerFunc(pprof.Trace)))
+	slog.Warn("Serving profiling endpoints on the admin server (ENABLE_PPROF)", "path", pprofPrefix)
+}
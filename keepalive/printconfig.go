@@ -0,0 +1,58 @@
+package keepalive
+
+import (
+	"io"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces a secret that is set.
+const redacted = "REDACTED"
+
+// Redacted returns c with every secret masked, so it is safe to print or
+// log: the password, the admin token, any userinfo in the connection
+// string and the path and query of the webhook URL, which often carry a
+// token.
+func (c Config) Redacted() Config {
+	if c.Password != "" {
+		c.Password = redacted
+	}
+	if c.AdminToken != "" {
+		c.AdminToken = redacted
+	}
+	c.ConnectionString = sanitizeConnectionString(c.ConnectionString)
+	if c.FailureWebhookURL != "" {
+		if u, err := url.Parse(c.FailureWebhookURL); err == nil && u.Host != "" {
+			c.FailureWebhookURL = u.Scheme + "://" + u.Host + "/" + redacted
+		} else {
+			c.FailureWebhookURL = redacted
+		}
+	}
+	c.Clusters = redactAll(c.Clusters)
+	c.Targets = redactAll(c.Targets)
+	return c
+}
+
+func redactAll(configs []Config) []Config {
+	if configs == nil {
+		return nil
+	}
+	out := make([]Config, len(configs))
+	for i, c := range configs {
+		out[i] = c.Redacted()
+	}
+	return out
+}
+
+// WriteConfig writes cfg as YAML with its secrets redacted, in the format
+// -config reads, so the effective result of defaults, files and
+// environment variables can be inspected.
+func WriteConfig(w io.Writer, cfg Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
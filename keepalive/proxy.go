@@ -0,0 +1,23 @@
+package keepalive
+
+import (
+	"log/slog"
+	"os"
+)
+
+// proxyEnvVars are the variables Go programs commonly honour for an egress
+// proxy.
+var proxyEnvVars = []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"}
+
+// warnIgnoredProxy warns when a proxy is configured in the environment. The
+// Couchbase SDK dials every node directly, for the KV protocol as well as
+// for its HTTP services, and offers no hook to route through a proxy, so the
+// setting would otherwise be silently ignored.
+func warnIgnoredProxy() {
+	for _, key := range proxyEnvVars {
+		if os.Getenv(key) != "" {
+			slog.Warn("Proxy environment variable is ignored: the Couchbase SDK connects to every node directly", "variable", key)
+			return
+		}
+	}
+}
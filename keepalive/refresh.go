@@ -0,0 +1,45 @@
+package keepalive
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// refreshCollections reconnects every interval until ctx is done, so a
+// collection that was dropped and recreated under the same name is picked
+// up without waiting for enough failures to reconnect. New Scope and
+// Collection handles on the same connection would not do: they only hold
+// names, and the SDK caches collection IDs per connection.
+func (c *clusterConn) refreshCollections(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		c.mu.Lock()
+		generation := c.generation
+		c.mu.Unlock()
+		// reconnect logs a failure itself, and the current connection is kept.
+		if err := c.reconnect(ctx, generation, nil); err == nil {
+			slog.Debug("Refreshed collection handles", "cluster", c.cfg.Name)
+		}
+	}
+}
+
+// rebind rebuilds every keepalive's strategy against fresh handles from the
+// current connection, which also resets per-strategy state such as a
+// fallback in use. Callers must hold c.mu.
+func (c *clusterConn) rebind() error {
+	strategies, err := c.strategies()
+	if err != nil {
+		return err
+	}
+	for i, k := range c.loops {
+		k.setStrategy(strategies[i].strategy, c.generation)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package keepalive
+
+import (
+	"log/slog"
+	"reflect"
+)
+
+// reloadableFields are the Config fields a SIGHUP applies to running loops.
+// Every other field only takes effect on startup, so cluster connections
+// are never torn down by a reload.
+var reloadableFields = map[string]bool{
+	"Interval":          true,
+	"HealthGracePeriod": true,
+}
+
+// reload applies the keepalive interval and health grace period from next
+// to every running loop. Changes to other settings, including anything
+// under targets, are reported as requiring a restart. It returns the config
+// now in effect.
+func reload(current, next Config, conns []*clusterConn) Config {
+	next.RunOnce = current.RunOnce
+
+	// Cluster entries are compared one by one below; the top level only
+	// needs its own comparison when it is not itself the single cluster.
+	split := len(current.Clusters) > 0 || len(current.BucketNames) > 0
+	if split || len(next.Clusters) > 0 || len(next.BucketNames) > 0 {
+		keep, clusters := current, next.Clusters
+		keep.Clusters, next.Clusters = nil, nil
+		next = applyReloadable(keep, next, "")
+		next.Clusters = clusters
+	}
+
+	nextClusters := make(map[string]Config)
+	for _, cc := range next.clusterConfigs() {
+		nextClusters[cc.Name] = cc
+	}
+	var applied []Config
+	for _, conn := range conns {
+		// conn.cfg is the config the connection started with and is not
+		// written here, since other goroutines read it; the reloaded
+		// settings reach the loops through their own synchronized state.
+		// c.mu guards the password a reconnect may rotate.
+		conn.mu.Lock()
+		running := conn.cfg
+		conn.mu.Unlock()
+		cc, ok := nextClusters[running.Name]
+		delete(nextClusters, running.Name)
+		if !ok {
+			slog.Warn("Cluster removed from configuration but requires a restart to take effect", "cluster", running.Name)
+			applied = append(applied, running)
+			continue
+		}
+		cfg := applyReloadable(running, cc, running.Name)
+		for _, k := range conn.loops {
+			if k.ownSchedule {
+				continue
+			}
+			k.health.setMaxAge(cfg.Interval + cfg.HealthGracePeriod)
+			k.setInterval(cfg.Interval)
+		}
+		applied = append(applied, cfg)
+	}
+	for name := range nextClusters {
+		slog.Warn("Cluster added to configuration but requires a restart to take effect", "cluster", name)
+	}
+
+	switch {
+	case len(current.Clusters) > 0:
+		next.Clusters = applied
+		return next
+	case len(current.BucketNames) > 0:
+		return next
+	}
+	return applied[0]
+}
+
+// applyReloadable returns current with the reloadable fields taken from
+// next, logging every other field that differs.
+func applyReloadable(current, next Config, cluster string) Config {
+	result := current
+	oldValue, newValue := reflect.ValueOf(current), reflect.ValueOf(next)
+	for i := range oldValue.NumField() {
+		name := oldValue.Type().Field(i).Name
+		if reloadableFields[name] {
+			reflect.ValueOf(&result).Elem().Field(i).Set(newValue.Field(i))
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			slog.Warn("Setting changed but requires a restart to take effect", "cluster", cluster, "setting", name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package keepalive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReloadAppliesIntervalWithoutRewritingConnConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Interval = time.Minute
+	k := &loop{name: "keepalive", health: newHealthState("keepalive", time.Minute), reset: make(chan time.Duration, 1)}
+	conn := &clusterConn{cfg: cfg, loops: []*loop{k}}
+
+	next := cfg
+	next.Interval = 2 * time.Minute
+	done := make(chan struct{})
+	go func() {
+		// Stands in for the loop and background goroutines reading the config.
+		defer close(done)
+		_ = conn.cfg.Interval
+	}()
+	applied := reload(cfg, next, []*clusterConn{conn})
+	<-done
+
+	if applied.Interval != 2*time.Minute {
+		t.Errorf("applied interval = %s, want 2m", applied.Interval)
+	}
+	if conn.cfg.Interval != time.Minute {
+		t.Errorf("conn.cfg.Interval = %s, want it left at 1m", conn.cfg.Interval)
+	}
+	select {
+	case interval := <-k.reset:
+		if interval != 2*time.Minute {
+			t.Errorf("loop interval = %s, want 2m", interval)
+		}
+	default:
+		t.Error("reload did not reset the loop's interval")
+	}
+}
@@ -0,0 +1,40 @@
+package keepalive
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// checkReplicas reads id from the active copy and every replica and logs
+// how many answered, as a smoke test of replication. It never fails the
+// keepalive: problems are only logged.
+func checkReplicas(ctx context.Context, target string, col *gocb.Collection, counter counterDoc) {
+	result, err := col.GetAllReplicas(counter.id, &gocb.GetAllReplicaOptions{
+		Timeout: counter.timeout,
+		Context: ctx,
+	})
+	if err != nil {
+		slog.Warn("Replica read failed", "target", target, "doc", counter.id, "category", errorCategory(err), "err", err)
+		return
+	}
+	var active, replicas int
+	for r := result.Next(); r != nil; r = result.Next() {
+		if r.IsReplica() {
+			replicas++
+		} else {
+			active++
+		}
+	}
+	if err := result.Close(); err != nil {
+		slog.Warn("Replica read failed", "target", target, "doc", counter.id, "category", errorCategory(err), "err", err)
+		return
+	}
+	keepaliveReplicasResponding.WithLabelValues(target).Set(float64(replicas))
+	if active == 0 {
+		slog.Warn("Active copy did not answer the replica read", "target", target, "doc", counter.id, "replicas", replicas)
+		return
+	}
+	slog.Info("Replica read", "target", target, "doc", counter.id, "replicas", replicas)
+}
@@ -0,0 +1,47 @@
+package keepalive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// SDK retry strategies. The SDK retries transient errors, such as a
+// temporary failure or a request sent to the wrong node during a rebalance,
+// within the operation timeout; our own retry policy only starts once that
+// has been used up.
+const (
+	sdkRetryBestEffort        = "best-effort"
+	sdkRetryNone              = "none"
+	defaultSDKRetryMaxBackoff = 500 * time.Millisecond
+	sdkRetryMinBackoff        = time.Millisecond
+)
+
+// checkSDKRetryStrategy reports whether name is a known SDK retry strategy.
+func checkSDKRetryStrategy(name string) error {
+	switch name {
+	case sdkRetryBestEffort, sdkRetryNone:
+		return nil
+	}
+	return fmt.Errorf("invalid SDK retry strategy %q: must be %s or %s (COUCHBASE_SDK_RETRY_STRATEGY)", name, sdkRetryBestEffort, sdkRetryNone)
+}
+
+// sdkRetryStrategy returns the RetryStrategy for the SDK cluster options.
+func sdkRetryStrategy(name string, maxBackoff time.Duration) gocb.RetryStrategy {
+	if name == sdkRetryNone {
+		return noRetryStrategy{}
+	}
+	return gocb.NewBestEffortRetryStrategy(func(attempts uint32) time.Duration {
+		return min(sdkRetryMinBackoff<<min(attempts, 30), maxBackoff)
+	})
+}
+
+// noRetryStrategy never asks the SDK to retry, leaving every retry to the
+// loop's backoff. The SDK still retries the few reasons it always must, such
+// as a request sent to the wrong node for its vBucket.
+type noRetryStrategy struct{}
+
+func (noRetryStrategy) RetryAfter(gocb.RetryRequest, gocb.RetryReason) gocb.RetryAction {
+	return &gocb.NoRetryRetryAction{}
+}
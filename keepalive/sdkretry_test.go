@@ -0,0 +1,54 @@
+package keepalive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// fakeRetryRequest is a request the SDK would hand the retry strategy after
+// attempts failed attempts.
+type fakeRetryRequest struct {
+	attempts uint32
+}
+
+func (r fakeRetryRequest) RetryAttempts() uint32 { return r.attempts }
+func (fakeRetryRequest) Identifier() string      { return "fake" }
+func (fakeRetryRequest) Idempotent() bool        { return true }
+func (fakeRetryRequest) RetryReasons() []gocb.RetryReason {
+	return []gocb.RetryReason{gocb.KVTemporaryFailureRetryReason}
+}
+
+func TestSDKRetryStrategyBestEffort(t *testing.T) {
+	const maxBackoff = 50 * time.Millisecond
+	strategy := sdkRetryStrategy(sdkRetryBestEffort, maxBackoff)
+	tests := []struct {
+		attempts uint32
+		want     time.Duration
+	}{
+		{0, sdkRetryMinBackoff},
+		{1, 2 * sdkRetryMinBackoff},
+		{3, 8 * sdkRetryMinBackoff},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, tt := range tests {
+		action := strategy.RetryAfter(fakeRetryRequest{attempts: tt.attempts}, gocb.KVTemporaryFailureRetryReason)
+		retry, ok := action.(*gocb.WithDurationRetryAction)
+		if !ok {
+			t.Fatalf("RetryAfter after %d attempts = %T, want a retry with a duration", tt.attempts, action)
+		}
+		if got := retry.Duration(); got != tt.want {
+			t.Errorf("backoff after %d attempts = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSDKRetryStrategyNone(t *testing.T) {
+	strategy := sdkRetryStrategy(sdkRetryNone, defaultSDKRetryMaxBackoff)
+	action := strategy.RetryAfter(fakeRetryRequest{}, gocb.KVTemporaryFailureRetryReason)
+	if _, ok := action.(*gocb.NoRetryRetryAction); !ok {
+		t.Errorf("RetryAfter = %T, want *gocb.NoRetryRetryAction", action)
+	}
+}
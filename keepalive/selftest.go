@@ -0,0 +1,78 @@
+package keepalive
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// selfTestDoc is the marker document the self-test writes and reads back.
+type selfTestDoc struct {
+	Marker    string    `json:"marker"`
+	WrittenAt time.Time `json:"written_at"`
+}
+
+// selfTest writes a marker document with a random key to every target
+// collection, reads it back, compares it and deletes it again.
+func (c *clusterConn) selfTest() error {
+	if c.cfg.ReadOnly {
+		return fmt.Errorf("cluster %q is read-only (READONLY), refusing to write the self-test document", c.cfg.Name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var failed bool
+	for _, tc := range c.cfg.keepaliveTargets() {
+		targets, err := c.collectionTargets(tc)
+		if err != nil {
+			return err
+		}
+		for _, t := range targets {
+			name := c.targetName(t.String())
+			if err := selfTestCollection(t.in(c.bucket), tc.OpTimeout); err != nil {
+				slog.Error("Self-test failed", "target", name, "category", errorCategory(err), "err", err)
+				failed = true
+				continue
+			}
+			slog.Info("Self-test passed", "target", name)
+		}
+	}
+	if failed {
+		return fmt.Errorf("self-test failed for one or more collections")
+	}
+	return nil
+}
+
+// selfTestCollection runs the write, read back and delete cycle in col.
+func selfTestCollection(col *gocb.Collection, timeout time.Duration) (err error) {
+	token := make([]byte, 8)
+	rand.Read(token)
+	marker := hex.EncodeToString(token)
+	id := "keepalive-selftest-" + marker
+	want := selfTestDoc{Marker: marker, WrittenAt: time.Now().UTC().Truncate(time.Millisecond)}
+
+	if _, err := col.Upsert(id, want, &gocb.UpsertOptions{Timeout: timeout}); err != nil {
+		return fmt.Errorf("upsert %s: %w", id, err)
+	}
+	defer func() {
+		if _, removeErr := col.Remove(id, &gocb.RemoveOptions{Timeout: timeout}); removeErr != nil && err == nil {
+			err = fmt.Errorf("remove %s: %w", id, removeErr)
+		}
+	}()
+
+	result, err := col.Get(id, &gocb.GetOptions{Timeout: timeout})
+	if err != nil {
+		return fmt.Errorf("get %s: %w", id, err)
+	}
+	var got selfTestDoc
+	if err := result.Content(&got); err != nil {
+		return fmt.Errorf("decode %s: %w", id, err)
+	}
+	if got.Marker != want.Marker || !got.WrittenAt.Equal(want.WrittenAt) {
+		return fmt.Errorf("read back %s does not match: wrote %+v, read %+v", id, want, got)
+	}
+	return nil
+}
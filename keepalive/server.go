@@ -0,0 +1,124 @@
+package keepalive
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAdminListenAddr = ":9090"
+	serverShutdownTimeout  = 5 * time.Second
+)
+
+// unixScheme marks a listen address as a Unix domain socket path.
+const unixScheme = "unix://"
+
+// listen opens a TCP listener on addr, or a Unix domain socket for an
+// address of the form unix:///path/to.sock. A socket file left behind by an
+// earlier run is replaced; the listener removes it again when closed.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixScheme)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// serveAdmin exposes /metrics, /healthz, /version, /pause, /resume, when
+// history is not nil, /history and, with profiling, /debug/pprof/ on addr
+// until ctx is cancelled, then shuts the server down gracefully before
+// returning. Metrics are labelled with instance unless it is empty. The
+// control and profiling endpoints are guarded by adminGuard with token.
+func serveAdmin(ctx context.Context, addr, instance, token string, health http.Handler, pause *pauseControl, history *history, profiling bool) {
+	guard := adminGuard{token: token, socket: strings.HasPrefix(addr, unixScheme)}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(instance))
+	mux.Handle("/healthz", health)
+	mux.HandleFunc("/version", serveVersion)
+	mux.Handle("/pause", guard.wrap(pause))
+	mux.Handle("/resume", guard.wrap(pause))
+	if history != nil {
+		mux.Handle("/history", history)
+	}
+	if profiling {
+		mountPprof(mux, guard)
+	}
+
+	lis, err := listen(addr)
+	if err != nil {
+		slog.Error("Admin server error", "err", err)
+		return
+	}
+	server := &http.Server{Handler: mux}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(lis)
+	}()
+	slog.Info("Serving admin endpoints", "addr", addr)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin server error", "err", err)
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down admin server", "err", err)
+	}
+}
+
+// adminGuard restricts the endpoints that change or reveal the running
+// process, /pause, /resume and /debug/pprof/, which would otherwise be open
+// to anyone who can reach the metrics port. With a token, requests must send
+// it as "Authorization: Bearer <token>". Without one, only clients on the
+// loopback interface are served, or any client of a Unix socket listener,
+// whose file permissions already decide who may connect.
+type adminGuard struct {
+	token  string
+	socket bool
+}
+
+func (g adminGuard) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(g.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if !g.socket && !isLoopback(r.RemoteAddr) {
+			http.Error(w, "forbidden: set ADMIN_TOKEN to allow remote clients", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether remoteAddr, a host:port, is a loopback address.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,41 @@
+package keepalive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminGuard(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name   string
+		guard  adminGuard
+		remote string
+		auth   string
+		want   int
+	}{
+		{"loopback without token", adminGuard{}, "127.0.0.1:50000", "", http.StatusOK},
+		{"ipv6 loopback without token", adminGuard{}, "[::1]:50000", "", http.StatusOK},
+		{"remote without token", adminGuard{}, "10.0.0.7:50000", "", http.StatusForbidden},
+		{"unix socket without token", adminGuard{socket: true}, "@", "", http.StatusOK},
+		{"remote with token", adminGuard{token: "s3cret"}, "10.0.0.7:50000", "Bearer s3cret", http.StatusOK},
+		{"remote with wrong token", adminGuard{token: "s3cret"}, "10.0.0.7:50000", "Bearer guess", http.StatusUnauthorized},
+		{"loopback missing token", adminGuard{token: "s3cret"}, "127.0.0.1:50000", "", http.StatusUnauthorized},
+		{"token without bearer", adminGuard{token: "s3cret"}, "10.0.0.7:50000", "s3cret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/pause", nil)
+			r.RemoteAddr = tt.remote
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			tt.guard.wrap(ok).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package keepalive
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultShutdownSignals stop the daemon on every platform.
+var defaultShutdownSignals = []string{"SIGINT", "SIGTERM"}
+
+// ParseSignals resolves SHUTDOWN_SIGNALS names such as SIGTERM, TERM or
+// interrupt to the platform's signals. SIGHUP is rejected because it
+// reloads the configuration.
+func ParseSignals(names []string) ([]os.Signal, error) {
+	var signals []os.Signal
+	for _, name := range names {
+		key := strings.ToUpper(name)
+		if key == "INTERRUPT" {
+			key = "SIGINT"
+		}
+		if !strings.HasPrefix(key, "SIG") {
+			key = "SIG" + key
+		}
+		if key == "SIGHUP" {
+			return nil, fmt.Errorf("invalid shutdown signal %q: SIGHUP reloads the configuration (SHUTDOWN_SIGNALS)", name)
+		}
+		sig, ok := signalNames[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid shutdown signal %q: not supported on this platform (SHUTDOWN_SIGNALS)", name)
+		}
+		signals = append(signals, sig)
+	}
+	if len(signals) == 0 {
+		return nil, fmt.Errorf("shutdown signals must not be empty (SHUTDOWN_SIGNALS)")
+	}
+	return signals, nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package keepalive
+
+import (
+	"os"
+	"syscall"
+)
+
+// On Windows, Ctrl+C and Ctrl+Break arrive as os.Interrupt and closing the
+// console or a service stop as SIGTERM.
+var signalNames = map[string]os.Signal{
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+}
@@ -0,0 +1,98 @@
+package keepalive
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// statusFile mirrors the latest counter values to a JSON file for quick
+// local inspection. Writes happen on a background goroutine so a slow or
+// full disk never stalls the keepalive loop.
+type statusFile struct {
+	path   string
+	host   string
+	notify chan struct{}
+
+	mu      sync.Mutex
+	targets map[string]targetStatus
+}
+
+type targetStatus struct {
+	Counter   uint64    `json:"counter"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type statusSnapshot struct {
+	Host    string                  `json:"host"`
+	Targets map[string]targetStatus `json:"targets"`
+}
+
+func newStatusFile(path string) *statusFile {
+	host, _ := os.Hostname()
+	return &statusFile{
+		path:    path,
+		host:    host,
+		notify:  make(chan struct{}, 1),
+		targets: make(map[string]targetStatus),
+	}
+}
+
+// record stores the latest counter for target and schedules a write. It is
+// a no-op on a nil statusFile.
+func (f *statusFile) record(target string, counter uint64) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.targets[target] = targetStatus{Counter: counter, UpdatedAt: time.Now().UTC()}
+	f.mu.Unlock()
+
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the file whenever a new value is recorded, until ctx is cancelled.
+func (f *statusFile) run(ctx context.Context) {
+	for {
+		select {
+		case <-f.notify:
+			if err := f.write(); err != nil {
+				slog.Warn("Could not write status file", "path", f.path, "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// write replaces the file atomically by writing a temporary file in the same
+// directory and renaming it over the target.
+func (f *statusFile) write() error {
+	f.mu.Lock()
+	data, err := json.MarshalIndent(statusSnapshot{Host: f.host, Targets: f.targets}, "", "  ")
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,236 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	strategyIncrement     = "increment"
+	strategyQuery         = "query"
+	defaultQueryStatement = "SELECT 1"
+)
+
+// KeepaliveStrategy is a single operation that keeps the connection warm.
+type KeepaliveStrategy interface {
+	Ping(ctx context.Context) error
+}
+
+// counterDoc describes the counter document and how it is written.
+type counterDoc struct {
+	id      string
+	timeout time.Duration
+	// expiry, when non-zero, makes the document expire if keepalives stop.
+	expiry time.Duration
+	// initial is the value a missing document is created with; delta is
+	// added on every later increment.
+	initial int64
+	delta   uint64
+	// max, when non-zero, is the value above which the document is reset
+	// to initial.
+	max uint64
+	// durability is the synchronous replication the increment waits for.
+	durability gocb.DurabilityLevel
+}
+
+// durabilityLevels maps lower-cased config names to SDK durability levels.
+var durabilityLevels = map[string]gocb.DurabilityLevel{
+	"none":                     gocb.DurabilityLevelNone,
+	"majority":                 gocb.DurabilityLevelMajority,
+	"majorityandpersistactive": gocb.DurabilityLevelMajorityAndPersistOnMaster,
+	"persisttomajority":        gocb.DurabilityLevelPersistToMajority,
+}
+
+// parseDurability resolves a durability name such as "majority".
+func parseDurability(name string) (gocb.DurabilityLevel, error) {
+	level, ok := durabilityLevels[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid durability %q: must be none, majority, majorityAndPersistActive or persistToMajority (COUCHBASE_DURABILITY)", name)
+	}
+	return level, nil
+}
+
+// incrementStrategy bumps a counter document in a collection. When ids
+// holds more than one document it bumps them in turn, one per tick, so the
+// keepalive reaches several vBuckets and therefore nodes.
+type incrementStrategy struct {
+	name    string
+	col     *gocb.Collection
+	counter counterDoc
+	ids     []string
+	next    *atomic.Uint64
+	status  *statusFile
+	values  *counterValues
+	// logEvery logs the counter on every logEvery-th success only, and
+	// replicaEvery, when non-zero, reads the document back from every
+	// replica on every replicaEvery-th success. successes counts them.
+	logEvery     int
+	replicaEvery int
+	successes    *atomic.Uint64
+}
+
+func (s incrementStrategy) Ping(ctx context.Context) error {
+	counter := s.counter
+	if len(s.ids) > 1 {
+		counter.id = s.ids[(s.next.Add(1)-1)%uint64(len(s.ids))]
+	}
+	current, cas, err := incrementCounter(ctx, binaryIncrementer{s.col.Binary()}, counter)
+	if err != nil {
+		return err
+	}
+	// The server only applies an increment's expiry when it creates the
+	// document, so a non-zero expiry is refreshed after every increment.
+	if counter.expiry > 0 {
+		touched, err := s.col.Touch(counter.id, counter.expiry, &gocb.TouchOptions{Timeout: counter.timeout, Context: ctx})
+		if err != nil {
+			return err
+		}
+		cas = touched.Cas()
+	}
+	var reset bool
+	if counter.max > 0 && current > counter.max {
+		reset, err = capCounter(ctx, s.col, counter, cas)
+		switch {
+		case err != nil:
+			slog.Warn("Could not reset counter above its max value", "target", s.name, "doc", counter.id, "counter", current, "err", err)
+		case reset:
+			slog.Info("Counter exceeded its max value, reset", "target", s.name, "doc", counter.id, "counter", current, "max", counter.max, "reset_to", counter.initial)
+			current = uint64(counter.initial)
+		default:
+			slog.Debug("Counter changed concurrently, leaving the reset to the other writer", "target", s.name, "doc", counter.id)
+		}
+	}
+	n := s.successes.Add(1)
+	if n%uint64(s.logEvery) == 0 {
+		slog.Debug("Counter incremented", "doc", counter.id, "counter", current)
+	}
+	if s.replicaEvery > 0 && n%uint64(s.replicaEvery) == 0 {
+		checkReplicas(ctx, s.name, s.col, counter)
+	}
+	s.status.record(s.name, current)
+	if reset {
+		s.values.reset(s.name, counter.id, current)
+	} else {
+		s.values.observe(s.name, counter.id, current)
+	}
+	return nil
+}
+
+// queryStrategy runs a read-only N1QL statement, in the query context of
+// scope when it is set and at the cluster level otherwise.
+type queryStrategy struct {
+	cluster   *gocb.Cluster
+	scope     *gocb.Scope
+	statement string
+	timeout   time.Duration
+}
+
+func (s queryStrategy) Ping(ctx context.Context) error {
+	opts := &gocb.QueryOptions{
+		Context:  ctx,
+		Timeout:  s.timeout,
+		Readonly: true,
+	}
+	var result *gocb.QueryResult
+	var err error
+	if s.scope != nil {
+		result, err = s.scope.Query(s.statement, opts)
+	} else {
+		result, err = s.cluster.Query(s.statement, opts)
+	}
+	if err != nil {
+		return err
+	}
+	// Drain the rows so the request completes and its connection is released.
+	for result.Next() {
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+	return result.Close()
+}
+
+// Incrementer is the part of a collection incrementCounter needs: an
+// increment returning the counter's new value and CAS. binaryIncrementer
+// implements it for a collection. It is an interface so tests can fake it;
+// a *gocb.CounterResult cannot be built outside the SDK.
+type Incrementer interface {
+	Increment(id string, opts *gocb.IncrementOptions) (uint64, gocb.Cas, error)
+}
+
+// binaryIncrementer increments through the collection's binary operations.
+type binaryIncrementer struct {
+	binary *gocb.BinaryCollection
+}
+
+func (b binaryIncrementer) Increment(id string, opts *gocb.IncrementOptions) (uint64, gocb.Cas, error) {
+	result, err := b.binary.Increment(id, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	return result.Content(), result.Cas(), nil
+}
+
+// incrementCounter atomically bumps the counter document, creating it if it
+// does not exist yet, and returns its new value and CAS. Cancelling ctx
+// abandons an increment still in flight.
+func incrementCounter(ctx context.Context, binary Incrementer, counter counterDoc) (uint64, gocb.Cas, error) {
+	return binary.Increment(counter.id, &gocb.IncrementOptions{
+		Context:         ctx,
+		Timeout:         counter.timeout,
+		Expiry:          counter.expiry,
+		Initial:         counter.initial,
+		Delta:           counter.delta,
+		DurabilityLevel: counter.durability,
+	})
+}
+
+// capCounter resets the counter document to its initial value once it has
+// grown past its max. The replace only applies while the document still has
+// cas, so when another instance bumped it in the meantime, this one backs
+// off and reports false: that instance sees the value above max too and
+// resets it itself.
+func capCounter(ctx context.Context, col *gocb.Collection, counter counterDoc, cas gocb.Cas) (bool, error) {
+	// Written as raw digits, like the server stores counters, so the next
+	// increment keeps working on it.
+	value := []byte(strconv.FormatInt(counter.initial, 10))
+	_, err := col.Replace(counter.id, value, &gocb.ReplaceOptions{
+		Transcoder:      gocb.NewRawJSONTranscoder(),
+		Cas:             cas,
+		Expiry:          counter.expiry,
+		DurabilityLevel: counter.durability,
+		Timeout:         counter.timeout,
+		Context:         ctx,
+	})
+	if errors.Is(err, gocb.ErrCasMismatch) || errors.Is(err, gocb.ErrDocumentNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// resetCounter deletes the counter document so the next increment starts
+// again from its initial value. A missing document is not an error.
+func resetCounter(col *gocb.Collection, counterDocID string, timeout time.Duration) error {
+	_, err := col.Remove(counterDocID, &gocb.RemoveOptions{Timeout: timeout})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil
+	}
+	return err
+}
+
+// checkStrategy reports whether name is a known strategy.
+func checkStrategy(name string) error {
+	switch name {
+	case strategyIncrement, strategyQuery, strategyPing, strategyTouch, strategyUpsert, strategyAnalytics, strategyTransaction:
+		return nil
+	}
+	return fmt.Errorf("invalid strategy %q: must be %s, %s, %s, %s, %s, %s or %s (KEEPALIVE_STRATEGY)", name, strategyIncrement, strategyQuery, strategyPing, strategyTouch, strategyUpsert, strategyAnalytics, strategyTransaction)
+}
@@ -0,0 +1,76 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// fakeIncrementer keeps counters in memory like the server does: a missing
+// counter is created with the initial value, an existing one is bumped by
+// the delta. err, when set, fails every increment.
+type fakeIncrementer struct {
+	values map[string]uint64
+	err    error
+	opts   *gocb.IncrementOptions
+}
+
+func (f *fakeIncrementer) Increment(id string, opts *gocb.IncrementOptions) (uint64, gocb.Cas, error) {
+	f.opts = opts
+	if f.err != nil {
+		return 0, 0, f.err
+	}
+	value, ok := f.values[id]
+	if ok {
+		value += opts.Delta
+	} else {
+		value = uint64(opts.Initial)
+	}
+	f.values[id] = value
+	return value, gocb.Cas(value), nil
+}
+
+func TestIncrementCounter(t *testing.T) {
+	counter := counterDoc{id: "counter", timeout: 2 * time.Second, expiry: time.Hour, initial: 1, delta: 2, durability: gocb.DurabilityLevelMajority}
+	tests := []struct {
+		name       string
+		values     map[string]uint64
+		err        error
+		increments int
+		want       uint64
+		wantErr    error
+	}{
+		{name: "creates the counter", values: map[string]uint64{}, increments: 1, want: 1},
+		{name: "bumps an existing counter", values: map[string]uint64{"counter": 41}, increments: 1, want: 43},
+		{name: "counts every increment", values: map[string]uint64{}, increments: 3, want: 5},
+		{name: "returns the error", values: map[string]uint64{}, err: gocb.ErrTimeout, increments: 1, wantErr: gocb.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeIncrementer{values: tt.values, err: tt.err}
+			var got uint64
+			var cas gocb.Cas
+			var err error
+			for range tt.increments {
+				got, cas, err = incrementCounter(context.Background(), fake, counter)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("incrementCounter error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("counter = %d, want %d", got, tt.want)
+			}
+			if tt.wantErr == nil && cas != gocb.Cas(tt.want) {
+				t.Errorf("cas = %d, want %d", cas, tt.want)
+			}
+			opts := fake.opts
+			if opts.Initial != counter.initial || opts.Delta != counter.delta || opts.Expiry != counter.expiry ||
+				opts.Timeout != counter.timeout || opts.DurabilityLevel != counter.durability {
+				t.Errorf("increment options = %+v, want them taken from %+v", opts, counter)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package keepalive
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// runStats accumulates the outcome of every keepalive attempt and reconnect
+// for the summary logged on shutdown. Its methods are no-ops on a nil
+// runStats.
+type runStats struct {
+	started    time.Time
+	attempts   atomic.Uint64
+	failures   atomic.Uint64
+	reconnects atomic.Uint64
+}
+
+// attempt records one keepalive attempt, retries included.
+func (s *runStats) attempt(err error) {
+	if s == nil {
+		return
+	}
+	s.attempts.Add(1)
+	if err != nil {
+		s.failures.Add(1)
+	}
+}
+
+// reconnect records one attempt to rebuild a cluster connection.
+func (s *runStats) reconnect() {
+	if s == nil {
+		return
+	}
+	s.reconnects.Add(1)
+}
+
+// final returns the last value of every counter document as
+// target:doc=value, sorted. It returns nil on a nil counterValues.
+func (v *counterValues) final() []string {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	counters := make([]string, 0, len(v.last))
+	for key, value := range v.last {
+		counters = append(counters, fmt.Sprintf("%s:%s=%d", key[0], key[1], value))
+	}
+	slices.Sort(counters)
+	return counters
+}
+
+// logSummary logs one line describing how the run went, so a CI run with
+// MAX_RUNTIME or a stopped daemon shows at a glance how it behaved.
+func (k *Keepalive) logSummary() {
+	attempts, failures := k.stats.attempts.Load(), k.stats.failures.Load()
+	var counters []string
+	for _, conn := range k.conns {
+		counters = append(counters, conn.counters.final()...)
+	}
+	slog.Info("Run summary",
+		"runtime", time.Since(k.stats.started).Round(time.Second),
+		"attempts", attempts,
+		"successes", attempts-failures,
+		"failures", failures,
+		"reconnects", k.stats.reconnects.Load(),
+		"counters", counters,
+	)
+}
@@ -0,0 +1,35 @@
+package keepalive
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// loadCACertPool reads a PEM bundle of CA certificates into a new pool.
+// The returned pool replaces the system roots, so it must contain every CA
+// needed to verify the cluster.
+func loadCACertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// warnInsecureTLS warns loudly when cfg skips TLS certificate verification,
+// which is only meant for throwaway clusters with self-signed certificates.
+func warnInsecureTLS(cfg Config, scheme string) {
+	if !cfg.TLSInsecure {
+		return
+	}
+	slog.Warn("INSECURE: TLS certificate verification is disabled, anyone on the network can impersonate the cluster; never use COUCHBASE_TLS_INSECURE in production", "cluster", cfg.Name)
+	if scheme == "couchbase" {
+		slog.Warn("COUCHBASE_TLS_INSECURE has no effect without TLS: use couchbases:// to connect over TLS", "cluster", cfg.Name)
+	}
+}
@@ -0,0 +1,86 @@
+package keepalive
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"slices"
+	"sort"
+	"time"
+)
+
+// observeTopology polls the SDK's diagnostics every interval and logs when
+// the set of nodes it is connected to changes, for example during a
+// rebalance or failover. It is best effort and never touches the keepalive
+// loops; a failed poll is only logged at debug level.
+func (c *clusterConn) observeTopology(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var nodes []string
+	for {
+		current, err := c.nodes()
+		if err != nil {
+			slog.Debug("Could not read cluster topology", "cluster", c.cfg.Name, "err", err)
+		} else if !slices.Equal(current, nodes) {
+			if nodes != nil {
+				added, removed := diffNodes(nodes, current)
+				slog.Warn("Cluster topology changed", "cluster", c.cfg.Name, "nodes", len(current), "added", added, "removed", removed)
+				keepaliveTopologyChanges.WithLabelValues(c.cfg.Name).Inc()
+			}
+			keepaliveClusterNodes.WithLabelValues(c.cfg.Name).Set(float64(len(current)))
+			nodes = current
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nodes returns the sorted hosts of every endpoint in the current
+// connection's diagnostics report.
+func (c *clusterConn) nodes() ([]string, error) {
+	c.mu.Lock()
+	cluster := c.cluster
+	c.mu.Unlock()
+	report, err := cluster.Diagnostics(nil)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, endpoints := range report.Services {
+		for _, endpoint := range endpoints {
+			host, _, err := net.SplitHostPort(endpoint.Remote)
+			if err != nil {
+				host = endpoint.Remote
+			}
+			if host != "" {
+				seen[host] = true
+			}
+		}
+	}
+	nodes := make([]string, 0, len(seen))
+	for host := range seen {
+		nodes = append(nodes, host)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// diffNodes reports the hosts in next but not prev, and in prev but not next.
+func diffNodes(prev, next []string) (added, removed []string) {
+	for _, host := range next {
+		if !slices.Contains(prev, host) {
+			added = append(added, host)
+		}
+	}
+	for _, host := range prev {
+		if !slices.Contains(next, host) {
+			removed = append(removed, host)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,40 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	strategyTouch      = "touch"
+	defaultTouchExpiry = 24 * time.Hour
+)
+
+// touchStrategy refreshes the expiry of the counter document without
+// changing its value, creating the document if it does not exist yet.
+type touchStrategy struct {
+	col     *gocb.Collection
+	id      string
+	expiry  time.Duration
+	timeout time.Duration
+}
+
+func (s touchStrategy) Ping(ctx context.Context) error {
+	_, err := s.col.Touch(s.id, s.expiry, &gocb.TouchOptions{Timeout: s.timeout, Context: ctx})
+	if !errors.Is(err, gocb.ErrDocumentNotFound) {
+		return err
+	}
+	// Another instance may create the document first, which is just as good.
+	_, err = s.col.Insert(s.id, map[string]any{"created_at": time.Now().UTC()}, &gocb.InsertOptions{
+		Expiry:  s.expiry,
+		Timeout: s.timeout,
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentExists) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package keepalive
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	otelServiceName = "couchbase-keepalive"
+	tracerName      = "github.com/tiennm99/couchbase-keepalive/keepalive"
+	spanName        = "couchbase.keepalive"
+)
+
+// checkOTLPEndpoint reports whether endpoint is an http or https URL.
+func checkOTLPEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid OTLP endpoint %q: want an http or https URL (OTEL_EXPORTER_OTLP_ENDPOINT)", endpoint)
+	}
+	return nil
+}
+
+// newTracerProvider returns a provider that batches spans to the OTLP/HTTP
+// collector at endpoint.
+func newTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", otelServiceName))),
+	), nil
+}
+
+// traceKeepalive runs op inside a couchbase.keepalive span recording the
+// target, strategy and result. With a nil tracer it just runs op, so
+// tracing costs nothing when it is not configured.
+func traceKeepalive(ctx context.Context, tracer trace.Tracer, target, strategy string, op func(context.Context) error) error {
+	if tracer == nil {
+		return op(ctx)
+	}
+	ctx, span := tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("keepalive.target", target),
+			attribute.String("keepalive.strategy", strategy),
+		),
+	)
+	defer span.End()
+
+	err := op(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("keepalive.result", "error"))
+		return err
+	}
+	span.SetAttributes(attribute.String("keepalive.result", "ok"))
+	return nil
+}
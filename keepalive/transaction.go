@@ -0,0 +1,66 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const strategyTransaction = "transaction"
+
+// transactionStrategy bumps the counter document inside a multi-document
+// transaction: it reads the document, writes it back incremented and
+// commits, inserting it first if it does not exist yet. That exercises the
+// transaction subsystem with its active transaction records and extra
+// round trips, so it is considerably heavier than a plain increment. The
+// counter stays a plain number, so it can be shared with the increment
+// strategy. Past its max it is written back as its initial value instead.
+type transactionStrategy struct {
+	name         string
+	transactions *gocb.Transactions
+	col          *gocb.Collection
+	counter      counterDoc
+	status       *statusFile
+	values       *counterValues
+}
+
+// Ping runs the transaction. The SDK takes no context for a transaction,
+// so it is bounded by its timeout rather than cancelled by ctx.
+func (s transactionStrategy) Ping(ctx context.Context) error {
+	var current uint64
+	var reset bool
+	_, err := s.transactions.Run(func(attempt *gocb.TransactionAttemptContext) error {
+		doc, err := attempt.Get(s.col, s.counter.id)
+		// The attempt may be retried, so each one starts afresh.
+		reset = false
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			current = uint64(s.counter.initial)
+			_, err = attempt.Insert(s.col, s.counter.id, current)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if err := doc.Content(&current); err != nil {
+			return err
+		}
+		current += s.counter.delta
+		if s.counter.max > 0 && current > s.counter.max {
+			current = uint64(s.counter.initial)
+			reset = true
+		}
+		_, err = attempt.Replace(doc, current)
+		return err
+	}, &gocb.TransactionOptions{Timeout: s.counter.timeout, DurabilityLevel: s.counter.durability})
+	if err != nil {
+		return err
+	}
+	s.status.record(s.name, current)
+	if reset {
+		s.values.reset(s.name, s.counter.id, current)
+	} else {
+		s.values.observe(s.name, s.counter.id, current)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package keepalive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+const (
+	strategyUpsert        = "upsert"
+	defaultHeartbeatDocID = "heartbeat"
+)
+
+// heartbeat is the document the upsert strategy writes: when and from where
+// it was written, plus the configured HEARTBEAT_FIELDS such as region or
+// version, so monitoring queries can filter heartbeats.
+type heartbeat struct {
+	TS     time.Time
+	Host   string
+	Fields map[string]string
+}
+
+// MarshalJSON writes the fields at the top level, next to ts and host.
+func (h heartbeat) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]any, len(h.Fields)+2)
+	for k, v := range h.Fields {
+		doc[k] = v
+	}
+	doc["ts"], doc["host"] = h.TS, h.Host
+	return json.Marshal(doc)
+}
+
+// upsertStrategy overwrites a small heartbeat document, for collections
+// where the binary increment opcode is not permitted.
+type upsertStrategy struct {
+	col     *gocb.Collection
+	id      string
+	fields  map[string]string
+	timeout time.Duration
+}
+
+func (s upsertStrategy) Ping(ctx context.Context) error {
+	host, _ := os.Hostname()
+	doc := heartbeat{TS: time.Now().UTC(), Host: host, Fields: s.fields}
+	_, err := s.col.Upsert(s.id, doc, &gocb.UpsertOptions{
+		Timeout: s.timeout,
+		Context: ctx,
+	})
+	return err
+}
+
+// fallbackStrategy runs primary until it fails with an error matched by
+// when, then logs msg and switches to fallback for good. It degrades the
+// increment strategy to an upsert when RBAC denies the increment and a
+// dropped collection to the default collection, each when configured.
+type fallbackStrategy struct {
+	name     string
+	msg      string
+	when     func(error) bool
+	primary  KeepaliveStrategy
+	fallback KeepaliveStrategy
+
+	mu         sync.Mutex
+	usePrimary bool
+}
+
+func newFallbackStrategy(name, msg string, when func(error) bool, primary, fallback KeepaliveStrategy) *fallbackStrategy {
+	return &fallbackStrategy{name: name, msg: msg, when: when, primary: primary, fallback: fallback, usePrimary: true}
+}
+
+// isAccessDenied matches the error the server returns when RBAC forbids an
+// operation, such as a user without write permission on the collection. The
+// SDK reports it as an authentication failure too, but a failed login, e.g.
+// after a password rotation, has a different status and is not matched.
+func isAccessDenied(err error) bool {
+	var kvErr *gocb.KeyValueError
+	return errors.As(err, &kvErr) && kvErr.StatusCode == memd.StatusAccessError
+}
+
+// isCollectionMissing matches the error for a scope or collection that no
+// longer exists.
+func isCollectionMissing(err error) bool {
+	return errors.Is(err, gocb.ErrCollectionNotFound) || errors.Is(err, gocb.ErrScopeNotFound)
+}
+
+func (s *fallbackStrategy) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	usePrimary := s.usePrimary
+	s.mu.Unlock()
+	if !usePrimary {
+		return s.fallback.Ping(ctx)
+	}
+
+	err := s.primary.Ping(ctx)
+	if err == nil || !s.when(err) {
+		return err
+	}
+	slog.Warn(s.msg, "target", s.name, "err", err)
+	s.mu.Lock()
+	s.usePrimary = false
+	s.mu.Unlock()
+	return s.fallback.Ping(ctx)
+}
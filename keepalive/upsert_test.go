@@ -0,0 +1,32 @@
+package keepalive
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+func TestIsAccessDenied(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rbac denied", &gocb.KeyValueError{InnerError: gocb.ErrAuthenticationFailure, StatusCode: memd.StatusAccessError}, true},
+		{"wrapped rbac denied", fmt.Errorf("increment: %w", &gocb.KeyValueError{InnerError: gocb.ErrAuthenticationFailure, StatusCode: memd.StatusAccessError}), true},
+		{"failed login", &gocb.KeyValueError{InnerError: gocb.ErrAuthenticationFailure, StatusCode: memd.StatusAuthError}, false},
+		{"authentication failure", gocb.ErrAuthenticationFailure, false},
+		{"timeout", gocb.ErrTimeout, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAccessDenied(tt.err); got != tt.want {
+				t.Errorf("isAccessDenied(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
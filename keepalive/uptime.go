@@ -0,0 +1,92 @@
+package keepalive
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultUptimeLogInterval = time.Hour
+
+// processStart approximates when the process started.
+var processStart = time.Now()
+
+// connectionAges remembers since when every cluster's current connection
+// has been up and exports it as keepalive_connection_age_seconds. A
+// reconnect restarts the age.
+type connectionAges struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+	desc  *prometheus.Desc
+}
+
+var connectionAge = newConnectionAges()
+
+// newConnectionAges returns an empty connectionAges registered with the
+// default Prometheus registry.
+func newConnectionAges() *connectionAges {
+	a := &connectionAges{
+		since: make(map[string]time.Time),
+		desc: prometheus.NewDesc("keepalive_connection_age_seconds",
+			"Seconds since the current cluster connection was established.", []string{"cluster"}, nil),
+	}
+	prometheus.MustRegister(a)
+	return a
+}
+
+// set starts the age of cluster's connection now when up, and forgets it
+// when the connection is down.
+func (a *connectionAges) set(cluster string, up bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if up {
+		a.since[cluster] = time.Now()
+	} else {
+		delete(a.since, cluster)
+	}
+}
+
+// ages returns the age of every connected cluster's connection.
+func (a *connectionAges) ages() map[string]time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ages := make(map[string]time.Duration, len(a.since))
+	for cluster, since := range a.since {
+		ages[cluster] = time.Since(since)
+	}
+	return ages
+}
+
+func (a *connectionAges) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.desc
+}
+
+func (a *connectionAges) Collect(ch chan<- prometheus.Metric) {
+	for cluster, age := range a.ages() {
+		ch <- prometheus.MustNewConstMetric(a.desc, prometheus.GaugeValue, age.Seconds(), cluster)
+	}
+}
+
+// logUptime logs the process uptime and the age of every cluster connection
+// every interval until ctx is done.
+func logUptime(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		slog.Info("Uptime", "uptime", time.Since(processStart).Round(time.Second))
+		ages := connectionAge.ages()
+		for _, cluster := range slices.Sorted(maps.Keys(ages)) {
+			slog.Info("Connection age", "cluster", cluster, "age", ages[cluster].Round(time.Second))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package keepalive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// verifyBucket checks through the management API that the bucket exists, so
+// a typo fails fast instead of timing out in WaitUntilReady. Errors other
+// than a missing bucket, such as the user lacking management permissions,
+// are logged and ignored.
+func verifyBucket(ctx context.Context, cluster *gocb.Cluster, name string, timeout time.Duration) error {
+	_, err := cluster.Buckets().GetBucket(name, &gocb.GetBucketOptions{Timeout: timeout, Context: ctx})
+	if errors.Is(err, gocb.ErrBucketNotFound) {
+		return fmt.Errorf("bucket %s not found", name)
+	}
+	if err != nil {
+		slog.Debug("Could not verify bucket, skipping", "bucket", name, "err", err)
+	}
+	return nil
+}
+
+// verifyTargets checks that the scope and collection of every target exist
+// in bucket. As with verifyBucket, a failure to list them is not an error.
+func verifyTargets(ctx context.Context, bucket *gocb.Bucket, targets []target, timeout time.Duration) error {
+	scopes, err := bucket.CollectionsV2().GetAllScopes(&gocb.GetAllScopesOptions{Timeout: timeout, Context: ctx})
+	if err != nil {
+		slog.Debug("Could not verify collections, skipping", "bucket", bucket.Name(), "err", err)
+		return nil
+	}
+	collections := make(map[string]map[string]bool)
+	for _, scope := range scopes {
+		collections[scope.Name] = make(map[string]bool)
+		for _, collection := range scope.Collections {
+			collections[scope.Name][collection.Name] = true
+		}
+	}
+
+	var errs []error
+	for _, t := range targets {
+		inScope, ok := collections[t.scope]
+		if !ok {
+			errs = append(errs, fmt.Errorf("scope %s not found in bucket %s", t.scope, bucket.Name()))
+			continue
+		}
+		if !inScope[t.collection] {
+			errs = append(errs, fmt.Errorf("collection %s not found in scope %s", t.collection, t.scope))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verifyScope checks that scope, configured by key, exists in bucket. As
+// with verifyBucket, a failure to list the scopes is not an error.
+func verifyScope(ctx context.Context, bucket *gocb.Bucket, scope string, timeout time.Duration, key string) error {
+	scopes, err := bucket.CollectionsV2().GetAllScopes(&gocb.GetAllScopesOptions{Timeout: timeout, Context: ctx})
+	if err != nil {
+		slog.Debug("Could not verify scope, skipping", "bucket", bucket.Name(), "err", err)
+		return nil
+	}
+	for _, s := range scopes {
+		if s.Name == scope {
+			return nil
+		}
+	}
+	return fmt.Errorf("scope %s not found in bucket %s (%s)", scope, bucket.Name(), key)
+}
+
+// ListCollections connects to the bucket of every cluster in cfg and writes
+// each of its collections to w as a scope.collection line, ready to paste
+// into COUCHBASE_COLLECTIONS. The configured collections are not checked.
+func ListCollections(ctx context.Context, cfg Config, w io.Writer) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	var errs []error
+	clusters := cfg.clusterConfigs()
+	for _, cc := range clusters {
+		if err := listCollections(ctx, cc, w, len(clusters) > 1); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %q: %w", cc.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func listCollections(ctx context.Context, cfg Config, w io.Writer, header bool) error {
+	cluster, bucket, err := connectBucket(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeCluster(cluster)
+
+	scopes, err := bucket.CollectionsV2().GetAllScopes(&gocb.GetAllScopesOptions{Timeout: cfg.ReadyTimeout, Context: ctx})
+	if err != nil {
+		return fmt.Errorf("list collections of bucket %s: %w", cfg.BucketName, err)
+	}
+	var names []string
+	for _, scope := range scopes {
+		for _, collection := range scope.Collections {
+			names = append(names, target{scope: scope.Name, collection: collection.Name}.String())
+		}
+	}
+	sort.Strings(names)
+
+	if header {
+		fmt.Fprintf(w, "# cluster %s, bucket %s\n", cfg.Name, cfg.BucketName)
+	}
+	for _, name := range names {
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
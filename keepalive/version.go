@@ -0,0 +1,60 @@
+package keepalive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at build time with for example
+//
+//	go build -ldflags "-X github.com/tiennm99/couchbase-keepalive/keepalive.Version=v1.2.3
+//	  -X github.com/tiennm99/couchbase-keepalive/keepalive.Commit=abc1234
+//	  -X github.com/tiennm99/couchbase-keepalive/keepalive.BuildDate=2024-01-02T15:04:05Z"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo describes the running build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// CurrentBuild returns the build information. When Commit was not set at
+// build time it falls back to the VCS revision the Go toolchain recorded.
+func CurrentBuild() BuildInfo {
+	b := BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if b.Commit == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range info.Settings {
+				if s.Key == "vcs.revision" {
+					b.Commit = s.Value
+				}
+			}
+		}
+	}
+	if b.Commit == "" {
+		b.Commit = "unknown"
+	}
+	if b.BuildDate == "" {
+		b.BuildDate = "unknown"
+	}
+	return b
+}
+
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("couchbase-keepalive %s (commit %s, built %s, %s)", b.Version, b.Commit, b.BuildDate, b.GoVersion)
+}
+
+// serveVersion responds with the build information as JSON.
+func serveVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CurrentBuild())
+}
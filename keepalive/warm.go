@@ -0,0 +1,43 @@
+package keepalive
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// warmServices pings services through bucket so the SDK opens their
+// connections now rather than on the first real request. It is best effort:
+// failures are logged and otherwise ignored.
+func warmServices(ctx context.Context, cluster string, bucket *gocb.Bucket, services []gocb.ServiceType, timeout time.Duration) {
+	start := time.Now()
+	result, err := bucket.Ping(&gocb.PingOptions{
+		ServiceTypes: services,
+		Timeout:      timeout,
+		Context:      ctx,
+	})
+	if err != nil {
+		slog.Warn("Could not warm service connections", "cluster", cluster, "err", err)
+		return
+	}
+	for service, endpoints := range result.Services {
+		for _, endpoint := range endpoints {
+			if endpoint.State != gocb.PingStateOk {
+				slog.Warn("Could not warm service connection", "cluster", cluster, "service", serviceName(service), "remote", endpoint.Remote, "err", endpoint.Error)
+			}
+		}
+	}
+	slog.Debug("Warmed service connections", "cluster", cluster, "services", len(services), "latency", time.Since(start))
+}
+
+// warm re-warms the configured services on the current connection.
+func (c *clusterConn) warm(ctx context.Context) {
+	// validate has already checked the services.
+	services, _ := parseServiceTypes(c.cfg.WarmServices, "WARM_SERVICES")
+	c.mu.Lock()
+	bucket := c.bucket
+	c.mu.Unlock()
+	warmServices(ctx, c.cfg.Name, bucket, services, c.cfg.OpTimeout)
+}
@@ -0,0 +1,140 @@
+package keepalive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookDebounce = time.Minute
+	webhookTimeout         = 5 * time.Second
+	webhookQueueSize       = 16
+)
+
+// webhookEvent is the JSON payload posted on a state change.
+type webhookEvent struct {
+	Host       string    `json:"host"`
+	Collection string    `json:"collection"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// webhook posts an event when a target goes from healthy to failing and
+// again when it recovers. A target's changes are reported at most once per
+// debounce period, so a flapping connection does not spam the endpoint;
+// whatever state it settles in is reported once the period has passed.
+type webhook struct {
+	url      string
+	host     string
+	debounce time.Duration
+	client   *http.Client
+	queue    chan webhookEvent
+
+	mu      sync.Mutex
+	targets map[string]*webhookTarget
+}
+
+type webhookTarget struct {
+	failing  bool
+	lastSent time.Time
+}
+
+func newWebhook(url string, debounce time.Duration) *webhook {
+	host, _ := os.Hostname()
+	return &webhook{
+		url:      url,
+		host:     host,
+		debounce: debounce,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan webhookEvent, webhookQueueSize),
+		targets:  make(map[string]*webhookTarget),
+	}
+}
+
+// checkWebhookURL reports whether u is an http or https URL.
+func checkWebhookURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid failure webhook URL %q: want an http or https URL (FAILURE_WEBHOOK_URL)", u)
+	}
+	return nil
+}
+
+// observe records the outcome of a tick of target and queues an event if
+// its state changed since the last one reported. It never blocks; events
+// are dropped if the queue is full. It is a no-op on a nil webhook.
+func (w *webhook) observe(target string, err error) {
+	if w == nil {
+		return
+	}
+	failing := err != nil
+
+	w.mu.Lock()
+	t, ok := w.targets[target]
+	if !ok {
+		t = &webhookTarget{}
+		w.targets[target] = t
+	}
+	if t.failing == failing || time.Since(t.lastSent) < w.debounce {
+		w.mu.Unlock()
+		return
+	}
+	t.failing = failing
+	t.lastSent = time.Now()
+	w.mu.Unlock()
+
+	event := webhookEvent{Host: w.host, Collection: target, Status: "recovered", Timestamp: time.Now().UTC()}
+	if failing {
+		event.Status = "failing"
+		event.Error = err.Error()
+	}
+	select {
+	case w.queue <- event:
+	default:
+		slog.Warn("Failure webhook queue full, dropping event", "target", target, "status", event.Status)
+	}
+}
+
+// run posts queued events until ctx is cancelled.
+func (w *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case event := <-w.queue:
+			if err := w.post(ctx, event); err != nil {
+				slog.Warn("Could not post failure webhook", "target", event.Collection, "status", event.Status, "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *webhook) post(ctx context.Context, event webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
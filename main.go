@@ -2,134 +2,218 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/couchbase/gocb/v2"
 	"github.com/joho/godotenv"
+
+	"github.com/tiennm99/couchbase-keepalive/keepalive"
 )
 
+// logOutput is where the logger writes once it is set up. exit closes it, since
+// os.Exit skips deferred calls.
+var logOutput io.Closer
+
 func main() {
+	configPath := flag.String("config", "", "path to a YAML or JSON config file")
+	once := flag.Bool("once", false, "perform a single keepalive and exit")
+	reset := flag.Bool("reset", false, "delete the counter document in every collection and exit")
+	confirm := flag.Bool("confirm", false, "confirm a destructive action such as -reset")
+	check := flag.Bool("check", false, "validate config and connectivity without running keepalives, then exit")
+	selfTest := flag.Bool("selftest", false, "write, read back and delete a marker document in every collection, then exit")
+	version := flag.Bool("version", false, "print version information and exit")
+	listCollections := flag.Bool("list-collections", false, "print every scope.collection in the bucket and exit")
+	foreground := flag.Bool("foreground", false, "debug mode: run the keepalives on the main goroutine with verbose output, firing immediately")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration as YAML, with secrets redacted, and exit")
+	flag.Parse()
+
+	if *version {
+		fmt.Println(keepalive.CurrentBuild())
+		return
+	}
+
 	// Uncomment following line to enable logging
 	// gocb.SetLogger(gocb.VerboseStdioLogger())
 
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found")
-	}
+	envErr := godotenv.Load()
 
-	// Update this to your cluster details
-	connectionString, isExist := os.LookupEnv("COUCHBASE_CONNECTION_STRING")
-	if !isExist {
-		log.Fatal("Warning: COUCHBASE_CONNECTION_STRING not set!")
-		return
+	cfg, err := keepalive.LoadConfig(*configPath)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
 	}
-	username, isExist := os.LookupEnv("COUCHBASE_USERNAME")
-	if !isExist {
-		log.Fatal("Warning: COUCHBASE_USERNAME not set!")
-		return
+	if *once {
+		cfg.RunOnce = true
 	}
-	password, isExist := os.LookupEnv("COUCHBASE_PASSWORD")
-	if !isExist {
-		log.Fatal("Warning: COUCHBASE_PASSWORD not set!")
+	if *printConfig {
+		if err := keepalive.WriteConfig(os.Stdout, cfg); err != nil {
+			fatal("Could not print configuration", "err", err)
+		}
 		return
 	}
-	bucketName, isExist := os.LookupEnv("COUCHBASE_BUCKET_NAME")
-	if !isExist {
-		log.Fatal("Warning: COUCHBASE_BUCKET_NAME not set!")
-		return
+	if *foreground {
+		cfg.LogLevel = "debug"
 	}
-	scopeName, isExist := os.LookupEnv("COUCHBASE_SCOPE_NAME")
-	if !isExist {
-		log.Fatal("Warning: COUCHBASE_SCOPE_NAME not set!")
-		return
+
+	output := keepalive.LogOutput(cfg)
+	logOutput = output
+	defer output.Close()
+	logger, err := keepalive.NewLogger(output, cfg.LogFormat, cfg.LogLevel, cfg.LogTimestamps, cfg.LogCaller)
+	if err != nil {
+		fatal("Invalid configuration", "err", err)
 	}
-	collectionName, isExist := os.LookupEnv("COUCHBASE_COLLECTION_NAME")
-	if !isExist {
-		log.Fatal("Warning: COUCHBASE_COLLECTION_NAME not set!")
-		return
+	if cfg.InstanceLabel != "" {
+		logger = logger.With("instance", cfg.InstanceLabel)
 	}
+	slog.SetDefault(logger)
 
-	options := gocb.ClusterOptions{
-		Authenticator: gocb.PasswordAuthenticator{
-			Username: username,
-			Password: password,
-		},
+	if envErr != nil {
+		slog.Warn(".env file not found")
 	}
 
-	// Sets a pre-configured profile called "wan-development" to help avoid latency issues
-	// when accessing Capella from a different Wide Area Network
-	// or Availability Zone (e.g. your laptop).
-	if err := options.ApplyProfile(gocb.ClusterConfigProfileWanDevelopment); err != nil {
-		log.Fatal(err)
+	if *reset {
+		if !*confirm {
+			fatal("-reset deletes the counter document; pass -confirm to proceed")
+		}
+		if err := cfg.ValidateTargets(); err != nil {
+			fatal("Invalid configuration", "err", err)
+		}
 	}
 
-	// Initialize the Connection
-	cluster, err := gocb.Connect(connectionString, options)
-	if err != nil {
-		log.Fatal(err)
+	if *listCollections {
+		if err := keepalive.ListCollections(context.Background(), cfg, os.Stdout); err != nil {
+			fatal("Could not list collections", "err", err)
+		}
+		return
 	}
 
-	bucket := cluster.Bucket(bucketName)
-
-	err = bucket.WaitUntilReady(5*time.Second, nil)
+	// A signal while connecting stops the connect retries.
+	connectCtx, stopConnect := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	k, err := keepalive.New(connectCtx, cfg)
+	stopConnect()
 	if err != nil {
-		log.Fatal(err)
+		fatal("Failed to start keepalive", "err", err)
 	}
 
-	// Get a reference to the default collection, required for older Couchbase server versions
-	// col := bucket.DefaultCollection()
-
-	col := bucket.Scope(scopeName).Collection(collectionName)
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	go func() {
-		ticker := time.NewTicker(time.Minute)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				if err := incrementCounter(col); err != nil {
-					log.Printf("Keepalive increment error: %v", err)
-				}
-			case <-ctx.Done():
-				return
-			}
+	if *check || *selfTest || *reset || cfg.RunOnce || *foreground {
+		switch {
+		case *check:
+			err = k.Check()
+		case *selfTest:
+			err = k.SelfTest()
+		case *reset:
+			err = k.Reset()
+		case cfg.RunOnce:
+			err = k.RunOnce(context.Background())
+		default:
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			err = k.RunForeground(ctx)
+			stop()
 		}
-	}()
+		if stopErr := k.Stop(); stopErr != nil {
+			slog.Warn("Timed out closing cluster", "err", stopErr)
+			exit(1)
+		}
+		if err != nil {
+			fatal("Keepalive failed", "err", err)
+		}
+		switch {
+		case *check:
+			slog.Info("Check passed", "clusters", k.Clusters())
+		case *selfTest:
+			slog.Info("Self-test passed", "clusters", k.Clusters())
+		}
+		return
+	}
 
+	// MAX_RUNTIME bounds the run; a signal still stops it earlier.
+	ctx := context.Background()
+	if cfg.MaxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.MaxRuntime)
+		defer cancel()
+	}
+	if err := k.Start(ctx); err != nil {
+		fatal("Failed to start keepalive", "err", err)
+	}
+	exitCode := 0
 	defer func() {
-		cancel()
-		if err := cluster.Close(nil); err != nil {
-			log.Printf("Error closing cluster: %v", err)
+		slog.Info("Shutting down")
+		if err := k.Stop(); err != nil {
+			slog.Warn("Shutdown timed out, forcing exit", "err", err)
+			exit(1)
+		}
+		if exitCode != 0 {
+			exit(exitCode)
 		}
 	}()
 
+	// New has already validated the signal names.
+	signals, _ := keepalive.ParseSignals(cfg.ShutdownSignals)
+	sig, err := waitForShutdown(ctx, k, signals, *configPath)
+	switch {
+	case sig != nil:
+		slog.Info("Received signal", "signal", sig)
+	case err != nil:
+		slog.Error("Giving up after consecutive keepalive failures", "err", err)
+		exitCode = 1
+	default:
+		slog.Info("Maximum runtime reached", "max_runtime", cfg.MaxRuntime)
+	}
+}
+
+// waitForShutdown blocks until one of signals arrives and returns it,
+// reloading the configuration on every SIGHUP in between. It returns the
+// error from k.Failed when the keepalive gives up, and neither when ctx is
+// done first.
+func waitForShutdown(ctx context.Context, k *keepalive.Keepalive, signals []os.Signal, configPath string) (os.Signal, error) {
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, append(signals, syscall.SIGHUP)...)
+	defer signal.Stop(sigCh)
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				reload(configPath, k)
+				continue
+			}
+			return sig, nil
+		case <-ctx.Done():
+			return nil, nil
+		case err := <-k.Failed():
+			return nil, err
+		}
+	}
 }
 
-func incrementCounter(col *gocb.Collection) error {
-	counterDocId := "counter"
-	docOut, err := col.Get(counterDocId, &gocb.GetOptions{})
-	if err != nil {
-		return err
+// reload re-reads the .env file and config file and applies the result to k.
+func reload(configPath string, k *keepalive.Keepalive) {
+	slog.Info("Reloading configuration")
+	if err := godotenv.Overload(); err != nil {
+		slog.Warn("Could not re-read .env file", "err", err)
 	}
-	var current uint64
-	err = docOut.Content(&current)
+	next, err := keepalive.LoadConfig(configPath)
 	if err != nil {
-		return err
+		slog.Error("Ignoring reload", "err", err)
+		return
 	}
-	current++
-	_, err = col.Upsert(counterDocId, current, &gocb.UpsertOptions{})
-	if err != nil {
-		return err
+	k.Reload(next)
+}
+
+// fatal logs msg at error level and exits with status 1.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	exit(1)
+}
+
+// exit closes the log output, flushing a log file, and exits with code.
+func exit(code int) {
+	if logOutput != nil {
+		logOutput.Close()
 	}
-	log.Printf("Counter : %d\n", current)
-	return nil
+	os.Exit(code)
 }